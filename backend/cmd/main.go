@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -12,9 +13,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/docker-logs-viewer/backend/internal/archive"
 	"github.com/docker-logs-viewer/backend/internal/db"
 	"github.com/docker-logs-viewer/backend/internal/docker"
 	"github.com/docker-logs-viewer/backend/internal/handlers"
+	"github.com/docker-logs-viewer/backend/internal/kubernetes"
+	"github.com/docker-logs-viewer/backend/internal/syslog"
 	"github.com/gorilla/mux"
 )
 
@@ -22,21 +26,87 @@ func main() {
 	listenAddr := flag.String("addr", ":8080", "HTTP listen address")
 	dbPath := flag.String("db", "/data/app.db", "Database path")
 	staticPath := flag.String("static", "/app/frontend", "Static files directory")
+	collectionWorkers := flag.Int("collection-workers", 16, "Maximum number of containers opening a log stream or polling at once; open follow streams do not count")
+	dbMaxOpenConns := flag.Int("db-max-open-conns", 10, "Maximum open database connections (0 for unlimited)")
+	dbMaxIdleConns := flag.Int("db-max-idle-conns", 5, "Maximum idle database connections")
+	dbBusyTimeout := flag.Duration("db-busy-timeout", 30*time.Second, "How long a database write waits for another writer before failing")
+	dbBusyRetries := flag.Int("db-busy-retries", 3, "Times a log insert or retention delete is retried with backoff after the busy timeout")
+	walCheckpoint := flag.String("wal-checkpoint", "adaptive", "WAL checkpoint strategy: adaptive (passive while writing, truncate when idle) or fixed (truncate every minute)")
+	walMaxPages := flag.Int("wal-max-pages", 10000, "With -wal-checkpoint=adaptive, truncate the WAL even during writes once it holds more pages than this (0 never does)")
+	lastLogFlushInterval := flag.Duration("last-log-flush-interval", 10*time.Second, "How often each container's resume position is written to the database; a crash re-reads at most this much (0 writes on every collection pass)")
+	dbConnMaxLifetime := flag.Duration("db-conn-max-lifetime", 5*time.Minute, "Maximum lifetime of a database connection (0 keeps connections open)")
+	compressThreshold := flag.Int("compress-threshold", 0, "Store log messages longer than this many bytes zlib-compressed (0 disables)")
+	overflowThreshold := flag.Int("overflow-threshold", 0, "Store log messages longer than this many bytes in a separate table to keep log scans fast (0 disables)")
+	maxWSClients := flag.Int("max-ws-clients", 1000, "Maximum concurrent WebSocket clients (0 for unlimited)")
+	binaryThreshold := flag.Float64("binary-threshold", 0, "Drop log lines whose fraction of non-printable bytes exceeds this value, e.g. 0.3 (0 disables)")
+	wsMaxMessageSize := flag.Int("ws-max-message-size", 512*1024, "Truncate log messages so WebSocket frames stay under this many bytes (0 disables)")
+	broadcastQueueSize := flag.Int("broadcast-queue-size", 1000, "Maximum WebSocket messages queued per container before new ones are dropped")
+	streamIdleTimeout := flag.Duration("stream-idle-timeout", 15*time.Minute, "Close /stream connections when neither the client nor the container has sent anything for this long (0 disables)")
+	unknownAfterFailures := flag.Int("unknown-after-failures", 3, "Consecutive failed inspects before a container's status becomes unknown")
+	listInspectWorkers := flag.Int("list-inspect-workers", 8, "Containers inspected in parallel when listing containers")
+	maxStreams := flag.Int("max-streams", 0, "Maximum concurrent Docker follow streams; other containers are polled in turn with short reads (0 for unlimited)")
+	absoluteMaxAge := flag.Duration("absolute-max-age", 0, "Delete logs older than this from every container, even ones without retention limits (0 disables)")
+	diskFullPrunePercent := flag.Int("disk-full-prune-percent", 10, "Percent of each container's oldest logs to delete when the database disk fills up (0 only waits for free space)")
+	archiveEndpoint := flag.String("archive-endpoint", "", "S3-compatible endpoint URL to archive logs to before retention deletes them")
+	archiveBucket := flag.String("archive-bucket", "", "Bucket for archived logs")
+	archiveRegion := flag.String("archive-region", "us-east-1", "Region used to sign archive requests")
+	archiveRequired := flag.Bool("archive-required", true, "Keep logs when archiving them fails instead of deleting anyway")
+	dockerContext := flag.String("docker-context", "", "Docker context to use when DOCKER_HOST is unset (defaults to the active context)")
+	dockerHosts := flag.String("docker-hosts", "", "Comma-separated daemon addresses, e.g. tcp://10.0.0.5:2375, that containers may be added with as dockerHost besides unix:// sockets")
+	resumeTailAfter := flag.Duration("resume-tail-after", 6*time.Hour, "When a container was silent longer than this, resume with the last -resume-tail-lines lines instead of the full backlog (0 disables)")
+	resumeTailLines := flag.Int("resume-tail-lines", 5000, "Lines to fetch when resuming after a long gap")
+	resumeSkew := flag.Duration("resume-skew", 0, "Re-read this much before the last stored line when resuming a log stream; lines already stored are skipped")
+	reconnectWindow := flag.Duration("reconnect-window", time.Minute, "Keep reopening a follow stream that broke off, with backoff, for up to this long before leaving it to the next collection pass (0 disables)")
+	quietSystemLogs := flag.Bool("quiet-system-logs", false, "Log container swaps to the server log instead of storing a [SYSTEM] line in the container's logs")
+	syslogAddr := flag.String("syslog", "", "Forward stored lines of containers with syslogForward enabled to this syslog server, e.g. tcp://host:514 or udp://host:514")
+	statusWebhook := flag.String("status-webhook", "", "URL that receives a JSON POST when a container changes status")
+	webhookDebounce := flag.Duration("status-webhook-debounce", 10*time.Second, "How long a new status must hold before the webhook is sent")
+	readOnly := flag.Bool("read-only", false, "Reject requests that add, remove or change containers or other server state")
+	pprofAddr := flag.String("pprof", "", "Serve Go runtime profiles on this address, e.g. localhost:6060 (disabled when empty; never expose it publicly)")
+	securityHeaders := flag.Bool("security-headers", true, "Send default security headers (CSP, X-Frame-Options, nosniff, Referrer-Policy)")
+	var extraHeaders headerFlags
+	flag.Var(&extraHeaders, "header", `Response header to add as "Name: value", repeatable; overrides a default, and an empty value removes it`)
+	kubeEnabled := flag.Bool("kubernetes", false, "Enable collecting logs from Kubernetes pods")
+	kubeAPIServer := flag.String("kube-api-server", "", "Kubernetes API server URL (defaults to the in-cluster service)")
+	kubeTokenFile := flag.String("kube-token-file", "", "Bearer token file for the Kubernetes API (defaults to the service account token)")
+	kubeCAFile := flag.String("kube-ca-file", "", "CA certificate for the Kubernetes API (defaults to the service account CA)")
 	flag.Parse()
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	database, err := db.NewSQLiteDB(*dbPath)
+	database, err := db.NewSQLiteDB(*dbPath, db.Options{
+		CompressThreshold:    *compressThreshold,
+		OverflowThreshold:    *overflowThreshold,
+		MaxOpenConns:         *dbMaxOpenConns,
+		MaxIdleConns:         *dbMaxIdleConns,
+		ConnMaxLifetime:      *dbConnMaxLifetime,
+		BusyTimeout:          *dbBusyTimeout,
+		BusyRetries:          *dbBusyRetries,
+		LastLogFlushInterval: *lastLogFlushInterval,
+		WALCheckpoint:        *walCheckpoint,
+		WALMaxPages:          *walMaxPages,
+	})
 	if err != nil {
 		log.Fatalf("[backend] Failed to open database: %v", err)
 	}
 	defer database.Close()
 
+	if *archiveEndpoint != "" {
+		archiver, err := archive.NewS3Archiver(*archiveEndpoint, *archiveBucket, *archiveRegion,
+			os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		if err != nil {
+			log.Fatalf("[backend] Failed to configure log archive: %v", err)
+		}
+		database.RetentionManager().SetArchiver(archiver, *archiveRequired)
+	}
+
+	database.RetentionManager().SetAbsoluteMaxAge(*absoluteMaxAge)
+
 	retentionCtx, retentionCancel := context.WithCancel(context.Background())
 	defer retentionCancel()
 	database.RetentionManager().Start(retentionCtx, 5*time.Minute)
 
-	dockerClient, err := docker.NewDockerClient()
+	dockerClient, err := docker.NewDockerClient(*dockerContext)
 	if err != nil {
 		log.Printf("[backend] Failed to create docker client: %v", err)
 	} else {
@@ -47,7 +117,53 @@ func main() {
 		}
 	}
 
-	server := handlers.NewServer(database, dockerClient, *staticPath)
+	var kubeClient *kubernetes.Client
+	if *kubeEnabled {
+		kubeClient, err = kubernetes.NewClient(*kubeAPIServer, *kubeTokenFile, *kubeCAFile)
+		if err != nil {
+			log.Fatalf("[backend] Failed to create kubernetes client: %v", err)
+		}
+	}
+
+	var syslogForwarder *syslog.Forwarder
+	if *syslogAddr != "" {
+		syslogForwarder, err = syslog.NewForwarder(*syslogAddr)
+		if err != nil {
+			log.Fatalf("[backend] Failed to configure syslog forwarding: %v", err)
+		}
+	}
+
+	var allowedDockerHosts []string
+	for _, host := range strings.Split(*dockerHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			allowedDockerHosts = append(allowedDockerHosts, host)
+		}
+	}
+
+	server := handlers.NewServer(database, dockerClient, handlers.Config{
+		StaticPath:           *staticPath,
+		CollectionWorkers:    *collectionWorkers,
+		MaxWSClients:         *maxWSClients,
+		BinaryThreshold:      *binaryThreshold,
+		WSMaxMessageSize:     *wsMaxMessageSize,
+		BroadcastQueueSize:   *broadcastQueueSize,
+		StreamIdleTimeout:    *streamIdleTimeout,
+		DiskFullPrunePercent: *diskFullPrunePercent,
+		MaxStreams:           *maxStreams,
+		UnknownAfterFailures: *unknownAfterFailures,
+		ListInspectWorkers:   *listInspectWorkers,
+		DockerHosts:          allowedDockerHosts,
+		Kubernetes:           kubeClient,
+		ResumeTailAfter:      *resumeTailAfter,
+		ResumeTailLines:      *resumeTailLines,
+		ResumeSkew:           *resumeSkew,
+		ReconnectWindow:      *reconnectWindow,
+		Syslog:               syslogForwarder,
+		QuietSystemLogs:      *quietSystemLogs,
+		StatusWebhook:        *statusWebhook,
+		WebhookDebounce:      *webhookDebounce,
+		ReadOnly:             *readOnly,
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -82,17 +198,50 @@ func main() {
 
 	r.HandleFunc("/api/health", server.HandleHealth)
 	r.HandleFunc("/api/containers", server.HandleListContainers).Methods("GET")
-	r.HandleFunc("/api/containers", server.HandleAddContainer).Methods("POST")
-	r.HandleFunc("/api/containers/{id}", server.HandleRemoveContainer).Methods("DELETE")
-	r.HandleFunc("/api/containers/{id}", server.HandleUpdateContainer).Methods("PUT")
+	r.HandleFunc("/api/containers", server.RequireWritable(server.HandleAddContainer)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}", server.RequireWritable(server.HandleRemoveContainer)).Methods("DELETE")
+	r.HandleFunc("/api/containers/{id}", server.RequireWritable(server.HandleUpdateContainer)).Methods("PUT")
+	r.HandleFunc("/api/containers/{id}/relink", server.RequireWritable(server.HandleRelinkContainer)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}/retention/apply", server.RequireWritable(server.HandleApplyRetention)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}/retention/preview", server.HandlePreviewRetention).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/transforms/preview", server.HandlePreviewTransforms).Methods("POST")
 	r.HandleFunc("/api/containers/{id}/logs", server.HandleGetLogs).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/logs/{logId}/pin", server.RequireWritable(server.HandlePinLog)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}/logs/{logId}/unpin", server.RequireWritable(server.HandleUnpinLog)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}/logs/{logId}/annotate", server.RequireWritable(server.HandleAnnotateLog)).Methods("POST")
+	r.HandleFunc("/api/containers/{id}/logs/context", server.HandleGetLogContext).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/logs/export", server.HandleExportLogs).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/logs/poll", server.HandlePollLogs).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/logs/levels", server.HandleGetLogLevels).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/logs/aggregate", server.HandleAggregateLogs).Methods("GET")
 	r.HandleFunc("/api/containers/{id}/stream", server.HandleStreamLogs).Methods("GET")
+	r.HandleFunc("/api/containers/{id}/replay", server.HandleReplay).Methods("GET")
 	r.HandleFunc("/api/ws/containers", server.HandleWSContainers).Methods("GET")
+	r.HandleFunc("/api/ws/merged", server.HandleWSMerged).Methods("GET")
 	r.HandleFunc("/api/ws/{id}", server.HandleWS).Methods("GET")
+	r.HandleFunc("/api/logs/merged", server.HandleGetMergedLogs).Methods("GET")
+	r.HandleFunc("/api/servers/{oldName}", server.RequireWritable(server.HandleRenameServer)).Methods("PUT")
 	r.HandleFunc("/api/docker/containers", server.HandleDockerContainers).Methods("GET")
+	r.HandleFunc("/api/docker/resolve", server.HandleResolveContainer).Methods("GET")
+	r.HandleFunc("/api/admin/ws-clients", server.HandleWSClients).Methods("GET")
+	r.HandleFunc("/api/admin/collectors", server.HandleListCollectors).Methods("GET")
+	r.HandleFunc("/api/admin/collectors/{id}/stop", server.RequireWritable(server.HandleStopCollector)).Methods("POST")
+	r.HandleFunc("/api/admin/retention/pause", server.RequireWritable(server.HandlePauseRetention)).Methods("POST")
+	r.HandleFunc("/api/admin/retention/resume", server.RequireWritable(server.HandleResumeRetention)).Methods("POST")
 
 	r.PathPrefix("/").Handler(staticHandler)
 
+	headers := map[string]string{}
+	if *securityHeaders {
+		for name, value := range defaultSecurityHeaders {
+			headers[name] = value
+		}
+	}
+	for _, h := range extraHeaders {
+		headers[h.name] = h.value
+	}
+	r.Use(responseHeaders(headers))
+
 	srv := &http.Server{
 		Addr:         *listenAddr,
 		Handler:      r,
@@ -101,6 +250,11 @@ func main() {
 		IdleTimeout:  30 * time.Second,
 	}
 
+	var pprofSrv *http.Server
+	if *pprofAddr != "" {
+		pprofSrv = startPprof(*pprofAddr)
+	}
+
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -116,6 +270,9 @@ func main() {
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Printf("[backend] Server shutdown error: %v", err)
 		}
+		if pprofSrv != nil {
+			pprofSrv.Close()
+		}
 	}()
 
 	log.Printf("[backend] Server listening on %s", *listenAddr)
@@ -126,6 +283,56 @@ func main() {
 	log.Printf("[backend] Server stopped")
 }
 
+var defaultSecurityHeaders = map[string]string{
+	"Content-Security-Policy": "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data:; font-src 'self' data:; connect-src 'self' ws: wss:; frame-ancestors 'self'",
+	"X-Frame-Options":        "SAMEORIGIN",
+	"X-Content-Type-Options": "nosniff",
+	"Referrer-Policy":        "strict-origin-when-cross-origin",
+}
+
+type header struct {
+	name  string
+	value string
+}
+
+type headerFlags []header
+
+func (h *headerFlags) String() string {
+	parts := make([]string, 0, len(*h))
+	for _, hdr := range *h {
+		parts = append(parts, hdr.name+": "+hdr.value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf(`expected "Name: value", got %q`, value)
+	}
+	*h = append(*h, header{name: http.CanonicalHeaderKey(name), value: strings.TrimSpace(val)})
+	return nil
+}
+
+// responseHeaders sets headers on every response except WebSocket upgrades.
+// Headers with an empty value are skipped so -header can disable a default.
+func responseHeaders(headers map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				for name, value := range headers {
+					if value != "" {
+						w.Header().Set(name, value)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 type staticFileHandler struct {
 	staticDir string
 	indexFile string
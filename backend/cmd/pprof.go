@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// startPprof serves the runtime profiles on their own listener so they are
+// never reachable through the public router.
+func startPprof(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	// No write timeout: CPU profiles and traces stream for as long as the
+	// requested ?seconds=.
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("[backend] pprof listening on %s", addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Printf("[backend] pprof server error: %v", err)
+		}
+	}()
+
+	return srv
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// ValidAggregateField accepts a field name of the parsed JSON fields, with
+// dots stepping into nested objects (e.g. "http.path").
+func ValidAggregateField(field string) bool {
+	if field == "" || strings.Contains(field, `"`) {
+		return false
+	}
+	for _, part := range strings.Split(field, ".") {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldPath quotes every step of field so names that are not plain
+// identifiers still work as a JSON1 path.
+func fieldPath(field string) string {
+	return `$."` + strings.ReplaceAll(field, ".", `"."`) + `"`
+}
+
+// AggregateField counts the logs matching q by the value of field in their
+// parsed JSON fields and returns the q.Limit most common values. Logs without
+// the field are not counted.
+func (s *SQLiteDB) AggregateField(q LogQuery, field string) (models.FieldAggregate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agg := models.FieldAggregate{Field: field, Values: []models.FieldValueCount{}}
+
+	where, args := q.where()
+	query := `SELECT value, COUNT(*), SUM(COUNT(*)) OVER (), COUNT(*) OVER ()
+	          FROM (SELECT json_extract(logs.fields, ?) AS value FROM ` + logSource + ` WHERE ` + where + ` AND logs.fields IS NOT NULL)
+	          WHERE value IS NOT NULL GROUP BY value ORDER BY COUNT(*) DESC, value LIMIT ?`
+	args = append([]interface{}{fieldPath(field)}, args...)
+	rows, err := s.db.Query(query, append(args, q.Limit)...)
+	if err != nil {
+		return agg, fmt.Errorf("failed to aggregate logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vc models.FieldValueCount
+		if err := rows.Scan(&vc.Value, &vc.Count, &agg.Total, &agg.Distinct); err != nil {
+			return agg, fmt.Errorf("failed to scan aggregate: %w", err)
+		}
+		// Strings and nested objects or arrays come back as text.
+		if b, ok := vc.Value.([]byte); ok {
+			vc.Value = string(b)
+		}
+		agg.Values = append(agg.Values, vc)
+	}
+
+	return agg, rows.Err()
+}
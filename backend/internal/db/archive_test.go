@@ -0,0 +1,65 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestArchivedContainerCanBeAddedAgain(t *testing.T) {
+	s := newTestDB(t, Options{})
+	archived := addTestContainer(t, s, "docker")
+	if err := s.ArchiveContainer(archived.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	live := addTestContainer(t, s, "docker")
+	if _, err := s.SwapContainer("docker", "recreated", "recreated"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := s.GetContainerByID(live.ID); c.ContainerID != "recreated" {
+		t.Fatalf("live container_id = %q, want recreated", c.ContainerID)
+	}
+	c, _ := s.GetContainerByID(archived.ID)
+	if !c.Archived || c.ContainerName != "docker" {
+		t.Fatalf("archived container = %+v, want it untouched by the swap", c)
+	}
+
+	// The same container can be archived twice over.
+	if err := s.ArchiveContainer(live.ID); err != nil {
+		t.Fatal(err)
+	}
+	addTestContainer(t, s, "recreated")
+}
+
+func TestArchivedContainerIDMigrated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := addTestContainer(t, s, "docker")
+	// As ArchiveContainer used to leave it.
+	if _, err := s.db.Exec(`UPDATE containers SET archived = 1 WHERE id = ?`, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Opened twice, to check the migration only suffixes the ID once.
+	s, err = NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	got, _ := s.GetContainerByID(c.ID)
+	if want := "docker:" + c.ID; got.ContainerID != want {
+		t.Fatalf("container_id = %q, want %q", got.ContainerID, want)
+	}
+	addTestContainer(t, s, "docker")
+}
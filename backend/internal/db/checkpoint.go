@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	CheckpointAdaptive = "adaptive"
+	CheckpointFixed    = "fixed"
+
+	// checkpointIdleAfter is how long without inserts before a TRUNCATE
+	// checkpoint, which waits for writers, is unlikely to stall one.
+	checkpointIdleAfter = 30 * time.Second
+)
+
+// Variables so benchmarks can run the loops at a faster pace.
+var (
+	fixedCheckpointInterval    = 60 * time.Second
+	adaptiveCheckpointInterval = 5 * time.Second
+)
+
+func validCheckpointMode(mode string) bool {
+	return mode == CheckpointAdaptive || mode == CheckpointFixed
+}
+
+func (s *SQLiteDB) walCheckpointLoop() {
+	if s.opts.WALCheckpoint == CheckpointFixed {
+		ticker := time.NewTicker(fixedCheckpointInterval)
+		defer ticker.Stop()
+		for s.waitCheckpoint(ticker) {
+			if _, err := s.checkpoint("TRUNCATE"); err != nil {
+				log.Printf("[backend] Failed to checkpoint WAL: %v", err)
+			}
+		}
+		return
+	}
+
+	ticker := time.NewTicker(adaptiveCheckpointInterval)
+	defer ticker.Stop()
+
+	truncated := false
+	for s.waitCheckpoint(ticker) {
+		if time.Since(time.Unix(0, s.lastInsert.Load())) >= checkpointIdleAfter {
+			if !truncated {
+				if _, err := s.checkpoint("TRUNCATE"); err != nil {
+					log.Printf("[backend] Failed to checkpoint WAL: %v", err)
+					continue
+				}
+				truncated = true
+			}
+			continue
+		}
+		truncated = false
+
+		// PASSIVE never waits for other connections, so it copies what it can
+		// without holding up inserts. Readers that keep it from finishing
+		// let the WAL grow; past WALMaxPages it is truncated regardless.
+		pages, err := s.checkpoint("PASSIVE")
+		if err != nil {
+			log.Printf("[backend] Failed to checkpoint WAL: %v", err)
+			continue
+		}
+		if s.opts.WALMaxPages > 0 && pages > s.opts.WALMaxPages {
+			log.Printf("[backend] WAL holds %d pages while logs are being written, truncating it", pages)
+			if _, err := s.checkpoint("TRUNCATE"); err != nil {
+				log.Printf("[backend] Failed to checkpoint WAL: %v", err)
+			}
+		}
+	}
+}
+
+// waitCheckpoint waits for the next tick and reports false once the database
+// is closed.
+func (s *SQLiteDB) waitCheckpoint(ticker *time.Ticker) bool {
+	select {
+	case <-ticker.C:
+		return true
+	case <-s.stopCheckpoint:
+		return false
+	}
+}
+
+// checkpoint runs a WAL checkpoint in the given mode and returns how many
+// pages the WAL held.
+func (s *SQLiteDB) checkpoint(mode string) (int, error) {
+	var busy, pages, checkpointed int
+	if err := s.db.QueryRow(`PRAGMA wal_checkpoint(`+mode+`)`).Scan(&busy, &pages, &checkpointed); err != nil {
+		return 0, fmt.Errorf("failed to run %s checkpoint: %w", mode, err)
+	}
+	return pages, nil
+}
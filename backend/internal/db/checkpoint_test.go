@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// BenchmarkInsertLatency inserts logs under sustained load while the WAL
+// checkpoint loop runs, with both loops sped up by the same factor, and
+// reports the spread of single insert latencies. Fixed TRUNCATE checkpoints
+// of a large WAL show up as a higher p99 than adaptive PASSIVE ones. Run it
+// with enough inserts for several fixed checkpoints, e.g. -benchtime 8000x.
+func BenchmarkInsertLatency(b *testing.B) {
+	defer func(fixed, adaptive time.Duration) {
+		fixedCheckpointInterval, adaptiveCheckpointInterval = fixed, adaptive
+	}(fixedCheckpointInterval, adaptiveCheckpointInterval)
+	fixedCheckpointInterval /= 30
+	adaptiveCheckpointInterval /= 30
+
+	message := strings.Repeat("x", 4000)
+	for _, mode := range []string{CheckpointFixed, CheckpointAdaptive} {
+		b.Run(mode, func(b *testing.B) {
+			s := newTestDB(b, Options{BusyTimeout: 5 * time.Second, WALCheckpoint: mode, WALMaxPages: 10000})
+			c := addTestContainer(b, s, "docker")
+
+			latencies := make([]time.Duration, b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				entry := &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: int64(i + 1), Message: message}
+				start := time.Now()
+				if err := s.AddLog(context.Background(), entry); err != nil {
+					b.Fatal(err)
+				}
+				latencies[i] = time.Since(start)
+			}
+			b.StopTimer()
+
+			slices.Sort(latencies)
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+			b.ReportMetric(float64(latencies[len(latencies)-1].Microseconds()), "max-µs")
+		})
+	}
+}
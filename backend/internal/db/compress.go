@@ -0,0 +1,64 @@
+package db
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the sqlite3 driver with zlib_text(blob) registered, which
+// lets a LIKE search read messages stored compressed.
+const driverName = "sqlite3_logs"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("zlib_text", decompressMessage, true)
+		},
+	})
+}
+
+// encodeMessage returns the values stored in the message, compressed and
+// message_blob columns. Compressed rows keep a digest of the original text in
+// message so the (tracked_container_id, timestamp, message) uniqueness still
+// deduplicates replayed lines.
+func (s *SQLiteDB) encodeMessage(message string) (string, bool, []byte, error) {
+	if s.opts.CompressThreshold <= 0 || len(message) <= s.opts.CompressThreshold {
+		return message, false, nil, nil
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write([]byte(message)); err != nil {
+		return "", false, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", false, nil, err
+	}
+
+	return messageDigest("zlib:", message), true, buf.Bytes(), nil
+}
+
+func messageDigest(prefix, message string) string {
+	digest := sha256.Sum256([]byte(message))
+	return prefix + hex.EncodeToString(digest[:])
+}
+
+func decompressMessage(blob []byte) (string, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestContainsSearchesCompressedMessages(t *testing.T) {
+	long := "panic: connection refused " + strings.Repeat("at frame ", 20)
+	for _, opts := range []Options{
+		{CompressThreshold: 64},
+		{CompressThreshold: 64, OverflowThreshold: 32},
+	} {
+		t.Run(fmt.Sprintf("overflow=%d", opts.OverflowThreshold), func(t *testing.T) {
+			s := newTestDB(t, opts)
+			c := addTestContainer(t, s, "docker")
+			addTestLog(t, s, c, 1, "short connection refused")
+			addTestLog(t, s, c, 2, long)
+			addTestLog(t, s, c, 3, "unrelated")
+
+			q := LogQuery{TrackedContainerID: c.ID, Contains: "CONNECTION REFUSED", Limit: 10}
+			logs, err := s.GetLogs(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != 2 || logs[0].Message != long {
+				t.Fatalf("got %d logs, want the compressed and the short match", len(logs))
+			}
+
+			count, err := s.CountLogs(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Fatalf("CountLogs = %d, want 2", count)
+			}
+
+			// The digest kept in logs.message must not match.
+			logs, err = s.GetLogs(LogQuery{TrackedContainerID: c.ID, Contains: "zlib:", Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != 0 {
+				t.Fatalf("search matched %d digests", len(logs))
+			}
+		})
+	}
+}
+
+// sampleWorkload returns the lines behind the compressed storage figures in
+// the README: 10% 30-frame stack traces, 20% ~1.4 KB JSON payloads and short
+// access-log lines for the rest.
+func sampleWorkload(n int) []string {
+	rng := rand.New(rand.NewSource(1))
+	lines := make([]string, n)
+	for i := range lines {
+		switch r := rng.Intn(10); {
+		case r == 0:
+			var trace strings.Builder
+			fmt.Fprintf(&trace, "Exception in thread \"worker-%d\" java.lang.IllegalStateException: request %d failed", rng.Intn(16), rng.Intn(1e6))
+			for frame := 0; frame < 30; frame++ {
+				fmt.Fprintf(&trace, "\n\tat com.example.service.Handler%d.process(Handler%d.java:%d)", frame, frame, rng.Intn(500))
+			}
+			lines[i] = trace.String()
+		case r <= 2:
+			var items []string
+			for item := 0; item < 12; item++ {
+				items = append(items, fmt.Sprintf(`{"sku":"SKU-%06d","quantity":%d,"price":%d.%02d,"warehouse":"eu-west-%d"}`, rng.Intn(1e6), rng.Intn(20), rng.Intn(500), rng.Intn(100), rng.Intn(3)))
+			}
+			lines[i] = fmt.Sprintf(`{"level":"info","msg":"order placed","order_id":%d,"customer":"cust-%d","items":[%s]}`, rng.Intn(1e9), rng.Intn(1e5), strings.Join(items, ","))
+		default:
+			lines[i] = fmt.Sprintf(`10.0.%d.%d - - "GET /api/items/%d HTTP/1.1" 200 %d %dms`, rng.Intn(256), rng.Intn(256), rng.Intn(1e4), rng.Intn(5000), rng.Intn(300))
+		}
+	}
+	return lines
+}
+
+// BenchmarkCompressedStorageSize stores sampleWorkload with and without
+// compression and reports the size of the vacuumed database file, e.g.
+// go test -run - -bench CompressedStorageSize -benchtime 1x ./internal/db
+func BenchmarkCompressedStorageSize(b *testing.B) {
+	lines := sampleWorkload(20000)
+	raw := 0
+	for _, line := range lines {
+		raw += len(line)
+	}
+
+	for _, threshold := range []int{0, 512} {
+		b.Run(fmt.Sprintf("threshold=%d", threshold), func(b *testing.B) {
+			var size int64
+			for i := 0; i < b.N; i++ {
+				path := filepath.Join(b.TempDir(), "logs.db")
+				s, err := NewSQLiteDB(path, Options{CompressThreshold: threshold, BusyTimeout: 5 * time.Second})
+				if err != nil {
+					b.Fatal(err)
+				}
+				c := addTestContainer(b, s, "docker")
+				for j, line := range lines {
+					entry := &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: int64(j + 1), Message: line}
+					if err := s.AddLog(context.Background(), entry); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := s.db.Exec(`VACUUM`); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+					b.Fatal(err)
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = info.Size()
+				s.Close()
+			}
+			b.ReportMetric(float64(raw)/1e6, "raw-MB")
+			b.ReportMetric(float64(size)/1e6, "db-MB")
+		})
+	}
+}
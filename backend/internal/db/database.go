@@ -3,31 +3,100 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/docker-logs-viewer/backend/internal/models"
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+const exportPageSize = 1000
+
+type Options struct {
+	// CompressThreshold is the message length in bytes above which messages
+	// are stored zlib-compressed. Zero disables compression.
+	CompressThreshold int
+
+	// OverflowThreshold is the message length in bytes above which the
+	// message is kept in the large_logs table instead of the logs row, so
+	// timestamp scans over logs stay fast. Zero disables it.
+	OverflowThreshold int
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// BusyTimeout is how long a connection waits for another writer's lock
+	// before failing with SQLITE_BUSY. BusyRetries is how many more times
+	// log inserts and retention deletes are attempted after that.
+	BusyTimeout time.Duration
+	BusyRetries int
+
+	// LastLogFlushInterval keeps last_log_timestamp updates in memory and
+	// writes them out this often and on Close. Zero writes them through.
+	LastLogFlushInterval time.Duration
+
+	// WALCheckpoint is CheckpointAdaptive or CheckpointFixed. Fixed
+	// truncates the WAL every minute. Adaptive runs PASSIVE checkpoints while
+	// logs are inserted and truncates once inserts pause, or once the WAL
+	// holds more than WALMaxPages pages.
+	WALCheckpoint string
+	WALMaxPages   int
+}
+
 type SQLiteDB struct {
 	db        *sql.DB
 	retention *RetentionManager
+	opts      Options
 	mu        sync.RWMutex
+
+	lastLogMu      sync.Mutex
+	pendingLastLog map[string]int64
+	stopFlush      chan struct{}
+	flushDone      chan struct{}
+
+	lastInsert     atomic.Int64
+	stopCheckpoint chan struct{}
+
+	// sizes caches GetContainerSizes between retention passes.
+	sizesMu sync.Mutex
+	sizes   map[string]int64
 }
 
-func NewSQLiteDB(path string) (*SQLiteDB, error) {
-	db, err := sql.Open("sqlite3", path)
+func NewSQLiteDB(path string, opts Options) (*SQLiteDB, error) {
+	if opts.WALCheckpoint == "" {
+		opts.WALCheckpoint = CheckpointAdaptive
+	}
+	if !validCheckpointMode(opts.WALCheckpoint) {
+		return nil, fmt.Errorf("unknown WAL checkpoint mode %q", opts.WALCheckpoint)
+	}
+
+	// Set through the DSN so every pooled connection gets it, not just the
+	// one a PRAGMA happens to run on.
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_busy_timeout=%d", path, separator, opts.BusyTimeout.Milliseconds())
+
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
@@ -37,31 +106,35 @@ func NewSQLiteDB(path string) (*SQLiteDB, error) {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
 
-	if _, err := db.Exec("PRAGMA busy_timeout=30000"); err != nil {
-		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
-	}
-
 	sdb := &SQLiteDB{
-		db:        db,
-		retention: NewRetentionManager(db),
+		db:             db,
+		opts:           opts,
+		pendingLastLog: make(map[string]int64),
+		stopCheckpoint: make(chan struct{}),
 	}
+	sdb.retention = NewRetentionManager(db, &sdb.mu)
+	sdb.retention.afterPass = func() {
+		if _, err := sdb.refreshContainerSizes(); err != nil {
+			log.Printf("[backend] Failed to refresh container sizes: %v", err)
+		}
+	}
+	sdb.retention.busyRetries = opts.BusyRetries
 
 	if err := sdb.createTables(); err != nil {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
-	go sdb.walCheckpointLoop()
+	sdb.retention.cleanupOrphans(context.Background())
 
-	return sdb, nil
-}
+	go sdb.walCheckpointLoop()
 
-func (s *SQLiteDB) walCheckpointLoop() {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
-		if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
-		}
+	if opts.LastLogFlushInterval > 0 {
+		sdb.stopFlush = make(chan struct{})
+		sdb.flushDone = make(chan struct{})
+		go sdb.lastLogFlushLoop(opts.LastLogFlushInterval)
 	}
+
+	return sdb, nil
 }
 
 func (s *SQLiteDB) createTables() error {
@@ -77,7 +150,33 @@ func (s *SQLiteDB) createTables() error {
 			max_period INTEGER DEFAULT 0,
 			max_lines INTEGER DEFAULT 0,
 			server_name TEXT DEFAULT '',
-			last_log_timestamp INTEGER DEFAULT 0
+			last_log_timestamp INTEGER DEFAULT 0,
+			expected_max_gap INTEGER DEFAULT 0,
+			metadata TEXT DEFAULT '{}',
+			source TEXT DEFAULT 'docker',
+			k8s_namespace TEXT DEFAULT '',
+			k8s_pod TEXT DEFAULT '',
+			k8s_container TEXT DEFAULT '',
+			log_details INTEGER DEFAULT 0,
+			detail_keys TEXT DEFAULT '',
+			log_format TEXT DEFAULT 'docker',
+			collect_stdout INTEGER DEFAULT 1,
+			collect_stderr INTEGER DEFAULT 1,
+			initial_lines INTEGER DEFAULT 0,
+			sample_rate INTEGER DEFAULT 1,
+			json_ts_field TEXT DEFAULT '',
+			json_ts_format TEXT DEFAULT '',
+			json_message_field TEXT DEFAULT '',
+			json_level_field TEXT DEFAULT '',
+			crash_looping INTEGER DEFAULT 0,
+			log_config TEXT DEFAULT '',
+			started_at INTEGER DEFAULT 0,
+			archived INTEGER DEFAULT 0,
+			transforms TEXT DEFAULT '',
+			inline_retention INTEGER DEFAULT 1,
+			syslog_forward INTEGER DEFAULT 0,
+			docker_host TEXT DEFAULT '',
+			max_period_in_days INTEGER DEFAULT 1
 		)`,
 		`CREATE TABLE IF NOT EXISTS logs (
 			id TEXT PRIMARY KEY,
@@ -85,9 +184,31 @@ func (s *SQLiteDB) createTables() error {
 			container_id TEXT NOT NULL,
 			timestamp INTEGER NOT NULL,
 			message TEXT NOT NULL,
+			compressed INTEGER DEFAULT 0,
+			message_blob BLOB,
+			level TEXT DEFAULT '',
+			overflow INTEGER DEFAULT 0,
+			details TEXT,
+			pinned INTEGER DEFAULT 0,
+			occurrence INTEGER NOT NULL DEFAULT 0,
+			stream TEXT DEFAULT '',
+			fields TEXT,
 			FOREIGN KEY (tracked_container_id) REFERENCES containers(id) ON DELETE CASCADE,
-			UNIQUE (tracked_container_id, timestamp, message)
+			UNIQUE (tracked_container_id, timestamp, message, occurrence)
+		)`,
+		`CREATE TABLE IF NOT EXISTS large_logs (
+			id TEXT PRIMARY KEY,
+			message_blob BLOB NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS annotations (
+			id TEXT PRIMARY KEY,
+			log_id TEXT NOT NULL,
+			tracked_container_id TEXT NOT NULL,
+			note TEXT NOT NULL,
+			author TEXT DEFAULT '',
+			created_at INTEGER NOT NULL
 		)`,
+		`CREATE INDEX IF NOT EXISTS idx_annotations_log ON annotations(log_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_logs_container_timestamp ON logs(tracked_container_id, timestamp DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_logs_container ON logs(tracked_container_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_containers_name ON containers(container_name)`,
@@ -123,25 +244,272 @@ func (s *SQLiteDB) runMigrations() error {
 		return err
 	}
 
-	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_containers_last_log ON containers(last_log_timestamp)`)
-	if err != nil && !strings.Contains(err.Error(), "index") {
+	_, err = s.db.Exec(`ALTER TABLE containers ADD COLUMN expected_max_gap INTEGER DEFAULT 0`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE containers ADD COLUMN metadata TEXT DEFAULT '{}'`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	for _, column := range []string{
+		`source TEXT DEFAULT 'docker'`,
+		`k8s_namespace TEXT DEFAULT ''`,
+		`k8s_pod TEXT DEFAULT ''`,
+		`k8s_container TEXT DEFAULT ''`,
+		`log_details INTEGER DEFAULT 0`,
+		`detail_keys TEXT DEFAULT ''`,
+		`log_format TEXT DEFAULT 'docker'`,
+		`collect_stdout INTEGER DEFAULT 1`,
+		`collect_stderr INTEGER DEFAULT 1`,
+		`initial_lines INTEGER DEFAULT 0`,
+		`sample_rate INTEGER DEFAULT 1`,
+		`json_ts_field TEXT DEFAULT ''`,
+		`json_ts_format TEXT DEFAULT ''`,
+		`json_message_field TEXT DEFAULT ''`,
+		`json_level_field TEXT DEFAULT ''`,
+		`crash_looping INTEGER DEFAULT 0`,
+		`log_config TEXT DEFAULT ''`,
+		`started_at INTEGER DEFAULT 0`,
+		`archived INTEGER DEFAULT 0`,
+		`transforms TEXT DEFAULT ''`,
+		`inline_retention INTEGER DEFAULT 1`,
+		`syslog_forward INTEGER DEFAULT 0`,
+		`docker_host TEXT DEFAULT ''`,
+	} {
+		_, err = s.db.Exec(`ALTER TABLE containers ADD COLUMN ` + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN compressed INTEGER DEFAULT 0`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN message_blob BLOB`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN level TEXT DEFAULT ''`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_logs_container_level ON logs(tracked_container_id, level)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN overflow INTEGER DEFAULT 0`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN details TEXT`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN pinned INTEGER DEFAULT 0`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_logs_pinned ON logs(tracked_container_id, timestamp DESC) WHERE pinned = 1`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	_, err = s.db.Exec(`CREATE TRIGGER IF NOT EXISTS logs_delete_overflow AFTER DELETE ON logs WHEN OLD.overflow = 1
+		BEGIN DELETE FROM large_logs WHERE id = OLD.id; END`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE TRIGGER IF NOT EXISTS logs_delete_annotations AFTER DELETE ON logs
+		BEGIN DELETE FROM annotations WHERE log_id = OLD.id; END`)
+	if err != nil {
 		return err
 	}
 
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_containers_last_log ON containers(last_log_timestamp)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
 	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_logs_container_timestamp ON logs(tracked_container_id, timestamp DESC)`)
-	if err != nil && !strings.Contains(err.Error(), "index") {
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	// Rows archived before archiveContainerID still hold the plain Docker ID.
+	_, err = s.db.Exec(`UPDATE containers SET container_id = ` + archiveContainerID + `
+		WHERE COALESCE(archived, 0) = 1 AND container_id NOT LIKE '%:' || id`)
+	if err != nil {
+		return err
+	}
+
+	if err := s.migrateLogOccurrence(); err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_logs_unique ON logs(tracked_container_id, timestamp, message)`)
-	if err != nil && !strings.Contains(err.Error(), "index") {
+	if err := s.migrateMaxPeriodDays(); err != nil {
 		return err
 	}
 
+	// Added after the occurrence rebuild, whose table doesn't have them.
+	for _, column := range []string{`stream TEXT DEFAULT ''`, `fields TEXT`} {
+		_, err = s.db.Exec(`ALTER TABLE logs ADD COLUMN ` + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// migrateMaxPeriodDays runs once on databases from before maxPeriod was
+// applied in days. It was compared as seconds against nanosecond timestamps
+// then, so it never expired anything; each container's maxPeriod is raised to
+// cover its oldest prunable log so that upgrading deletes none of them. The
+// max_period_in_days column marks databases that have been converted.
+func (s *SQLiteDB) migrateMaxPeriodDays() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`ALTER TABLE containers ADD COLUMN max_period_in_days INTEGER DEFAULT 1`)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		}
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT containers.id, containers.container_name, containers.max_period, MIN(logs.timestamp)
+		FROM containers JOIN logs ON logs.tracked_container_id = containers.id
+		WHERE containers.max_period > 0 AND ` + prunable + `
+		GROUP BY containers.id`)
+	if err != nil {
+		return err
+	}
+	type raise struct {
+		id, name string
+		from, to int64
+	}
+	var raises []raise
+	const day = int64(24 * time.Hour)
+	now := time.Now().UnixNano()
+	for rows.Next() {
+		var r raise
+		var oldest int64
+		if err := rows.Scan(&r.id, &r.name, &r.from, &oldest); err != nil {
+			rows.Close()
+			return err
+		}
+		r.to = (now - oldest + day - 1) / day
+		if r.to > r.from {
+			raises = append(raises, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range raises {
+		if _, err := tx.Exec(`UPDATE containers SET max_period = ? WHERE id = ?`, r.to, r.id); err != nil {
+			return err
+		}
+		log.Printf("[backend] maxPeriod is now applied in days: raised %s from %d to %d days to keep its stored logs", r.name, r.from, r.to)
+	}
+
+	return tx.Commit()
+}
+
+// migrateLogOccurrence rebuilds logs tables created before the occurrence
+// column, since SQLite cannot change a table's UNIQUE constraint in place.
+// The table's indexes and triggers are recreated as they were, except the old
+// idx_logs_unique that the new constraint replaces.
+func (s *SQLiteDB) migrateLogOccurrence() error {
+	var migrated int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('logs') WHERE name = 'occurrence'`).Scan(&migrated)
+	if err != nil || migrated > 0 {
+		return err
+	}
+
+	log.Printf("[backend] Rebuilding the logs table so repeated identical lines can be stored; this can take a while on large databases")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT sql FROM sqlite_master WHERE tbl_name = 'logs' AND type IN ('index', 'trigger') AND sql IS NOT NULL AND name != 'idx_logs_unique'`)
+	if err != nil {
+		return err
+	}
+	var recreate []string
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			rows.Close()
+			return err
+		}
+		recreate = append(recreate, stmt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// rowid is copied too: it is the seq clients resume from with afterSeq.
+	const columns = `rowid, id, tracked_container_id, container_id, timestamp, message, compressed, message_blob, level, overflow, details, pinned`
+	statements := []string{
+		`CREATE TABLE logs_rebuild (
+			id TEXT PRIMARY KEY,
+			tracked_container_id TEXT NOT NULL,
+			container_id TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			compressed INTEGER DEFAULT 0,
+			message_blob BLOB,
+			level TEXT DEFAULT '',
+			overflow INTEGER DEFAULT 0,
+			details TEXT,
+			pinned INTEGER DEFAULT 0,
+			occurrence INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (tracked_container_id) REFERENCES containers(id) ON DELETE CASCADE,
+			UNIQUE (tracked_container_id, timestamp, message, occurrence)
+		)`,
+		`INSERT INTO logs_rebuild (` + columns + `) SELECT ` + columns + ` FROM logs`,
+		`DROP TABLE logs`,
+		`ALTER TABLE logs_rebuild RENAME TO logs`,
+	}
+	for _, statement := range append(statements, recreate...) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild logs table: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Close writes out cached last_log_timestamp updates and closes the database.
 func (s *SQLiteDB) Close() error {
+	close(s.stopCheckpoint)
+	if s.stopFlush != nil {
+		close(s.stopFlush)
+		<-s.flushDone
+	}
 	return s.db.Close()
 }
 
@@ -149,10 +517,41 @@ func (s *SQLiteDB) AddContainer(req *models.AddContainerRequest, containerID, co
 	id := uuid.New().String()
 	now := time.Now().Unix()
 
-	query := `INSERT INTO containers (id, container_id, container_name, alias, added_at, swapped_at, status, max_period, max_lines, server_name, last_log_timestamp)
-	          VALUES (?, ?, ?, ?, ?, ?, 'unknown', ?, ?, ?, ?)`
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = "{}"
+	}
+
+	source := req.Source
+	if source == "" {
+		source = models.SourceDocker
+	}
+
+	query := `INSERT INTO containers (id, container_id, container_name, alias, added_at, swapped_at, status, max_period, max_lines, server_name, last_log_timestamp, expected_max_gap, metadata,
+	          source, k8s_namespace, k8s_pod, k8s_container, log_details, detail_keys, log_format, collect_stdout, collect_stderr, initial_lines, sample_rate,
+	          json_ts_field, json_ts_format, json_message_field, json_level_field, transforms, inline_retention, syslog_forward, docker_host)
+	          VALUES (?, ?, ?, ?, ?, ?, 'unknown', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	logFormat := req.LogFormat
+	if logFormat == "" {
+		logFormat = models.LogFormatDocker
+	}
+
+	sampleRate := max(req.SampleRate, 1)
 
-	_, err := s.db.Exec(query, id, containerID, containerName, req.Alias, now, now, req.MaxPeriod, req.MaxLines, serverName, now)
+	transforms, err := encodeTransforms(req.Transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(query, id, containerID, containerName, req.Alias, now, now, req.MaxPeriod, req.MaxLines, serverName, now, req.ExpectedMaxGap, metadata,
+		source, req.Namespace, req.Pod, req.PodContainer, req.LogDetails, strings.Join(req.DetailKeys, ","), logFormat,
+		req.CollectStdout == nil || *req.CollectStdout, req.CollectStderr == nil || *req.CollectStderr, req.InitialLines, sampleRate,
+		req.JSONTimestampField, req.JSONTimestampFormat, req.JSONMessageField, req.JSONLevelField, transforms,
+		req.InlineRetention == nil || *req.InlineRetention, req.SyslogForward, req.DockerHost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add container: %w", err)
 	}
@@ -164,46 +563,105 @@ func (s *SQLiteDB) SwapContainer(oldContainerID, newContainerID, newName string)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin swap: %w", err)
+	}
+	defer tx.Rollback()
+
 	var oldLastLogTs int64
 	var internalID string
-	err := s.db.QueryRow(`SELECT id, last_log_timestamp FROM containers WHERE container_id = ?`, oldContainerID).Scan(&internalID, &oldLastLogTs)
+	err = tx.QueryRow(`SELECT id, last_log_timestamp FROM containers WHERE container_id = ? AND COALESCE(archived, 0) = 0`, oldContainerID).Scan(&internalID, &oldLastLogTs)
 	if err != nil && err != sql.ErrNoRows {
 		return 0, err
 	}
 
 	now := time.Now().Unix()
-	query := `UPDATE containers SET container_id = ?, container_name = ?, swapped_at = ?, last_log_timestamp = ? WHERE id = ?`
-	_, err = s.db.Exec(query, newContainerID, newName, now, oldLastLogTs, internalID)
+	// started_at is read again from the new container on the next status pass.
+	query := `UPDATE containers SET container_id = ?, container_name = ?, swapped_at = ?, last_log_timestamp = ?, started_at = 0 WHERE id = ?`
+	_, err = tx.Exec(query, newContainerID, newName, now, oldLastLogTs, internalID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to swap container: %w", err)
 	}
 
-	_, err = s.db.Exec(`UPDATE logs SET container_id = ? WHERE tracked_container_id = ?`, newContainerID, internalID)
+	_, err = tx.Exec(`UPDATE logs SET container_id = ? WHERE tracked_container_id = ?`, newContainerID, internalID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to swap container logs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit swap: %w", err)
+	}
 
 	return oldLastLogTs, nil
 }
 
-func (s *SQLiteDB) GetContainerByID(id string) (*models.Container, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+const containerColumns = `id, container_id, container_name, alias, added_at, swapped_at, status, max_period, max_lines, server_name, expected_max_gap, metadata,
+	source, k8s_namespace, k8s_pod, k8s_container, log_details, detail_keys, log_format,
+	COALESCE(collect_stdout, 1), COALESCE(collect_stderr, 1), COALESCE(initial_lines, 0),
+	COALESCE(sample_rate, 1), COALESCE(json_ts_field, ''), COALESCE(json_ts_format, ''),
+	COALESCE(json_message_field, ''), COALESCE(json_level_field, ''), COALESCE(crash_looping, 0),
+	COALESCE(log_config, ''), COALESCE(started_at, 0), COALESCE(archived, 0), COALESCE(transforms, ''),
+	COALESCE(inline_retention, 1), COALESCE(syslog_forward, 0), COALESCE(docker_host, '')`
 
-	query := `SELECT id, container_id, container_name, alias, added_at, swapped_at, status, max_period, max_lines, server_name
-	          FROM containers WHERE id = ?`
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+func scanContainer(row rowScanner) (models.Container, error) {
 	var c models.Container
-	var alias, serverName sql.NullString
-	var maxPeriod sql.NullInt64
-	var maxLines sql.NullInt64
+	var alias, serverName, metadata, source, namespace, pod, podContainer, detailKeys, logFormat sql.NullString
+	var logConfig, transforms string
+	var maxPeriod, maxLines, expectedMaxGap sql.NullInt64
+	var logDetails sql.NullBool
 
-	err := s.db.QueryRow(query, id).Scan(
+	if err := row.Scan(
 		&c.ID, &c.ContainerID, &c.ContainerName, &alias, &c.AddedAt, &c.SwappedAt,
-		&c.Status, &maxPeriod, &maxLines, &serverName,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		&c.Status, &maxPeriod, &maxLines, &serverName, &expectedMaxGap, &metadata,
+		&source, &namespace, &pod, &podContainer, &logDetails, &detailKeys, &logFormat,
+		&c.CollectStdout, &c.CollectStderr, &c.InitialLines, &c.SampleRate,
+		&c.JSONTimestampField, &c.JSONTimestampFormat, &c.JSONMessageField, &c.JSONLevelField,
+		&c.CrashLooping, &logConfig, &c.StartedAt, &c.Archived, &transforms,
+		&c.InlineRetention, &c.SyslogForward, &c.DockerHost,
+	); err != nil {
+		return c, err
+	}
+
+	c.LogDetails = logDetails.Bool
+	c.LogFormat = logFormat.String
+	if c.LogFormat == "" {
+		c.LogFormat = models.LogFormatDocker
+	}
+	if detailKeys.String != "" {
+		c.DetailKeys = strings.Split(detailKeys.String, ",")
+	}
+
+	c.Source = source.String
+	if c.Source == "" {
+		c.Source = models.SourceDocker
+	}
+	c.Namespace = namespace.String
+	c.Pod = pod.String
+	c.PodContainer = podContainer.String
+
+	if logConfig != "" {
+		c.LogConfig = &models.LogConfig{}
+		if err := json.Unmarshal([]byte(logConfig), c.LogConfig); err != nil {
+			return c, fmt.Errorf("failed to decode log config: %w", err)
+		}
+	}
+
+	if transforms != "" {
+		if err := json.Unmarshal([]byte(transforms), &c.Transforms); err != nil {
+			return c, fmt.Errorf("failed to decode transforms: %w", err)
+		}
+	}
+
+	c.Metadata = map[string]string{}
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &c.Metadata); err != nil {
+			return c, fmt.Errorf("failed to decode metadata: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get container: %w", err)
 	}
 
 	c.Alias = alias.String
@@ -214,18 +672,118 @@ func (s *SQLiteDB) GetContainerByID(id string) (*models.Container, error) {
 	if maxLines.Valid {
 		c.MaxLines = int(maxLines.Int64)
 	}
+	if expectedMaxGap.Valid {
+		c.ExpectedMaxGap = expectedMaxGap.Int64
+	}
+
+	return c, nil
+}
+
+func encodeMetadata(metadata map[string]string) (interface{}, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeTransforms stores rules as JSON, and no rules as an empty string.
+func encodeTransforms(rules []models.TransformRule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transforms: %w", err)
+	}
+	return string(data), nil
+}
+
+func (s *SQLiteDB) GetContainerByID(id string) (*models.Container, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + containerColumns + ` FROM containers WHERE id = ?`
+
+	c, err := scanContainer(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get container: %w", err)
+	}
 
 	return &c, nil
 }
 
+var containerSortColumns = map[string]string{
+	"alias":   "COALESCE(NULLIF(alias, ''), container_name)",
+	"name":    "container_name",
+	"status":  "status",
+	"addedAt": "added_at",
+}
+
+func ValidContainerSort(sort string) bool {
+	_, ok := containerSortColumns[sort]
+	return ok
+}
+
+// containerStatuses are the states Docker reports, plus "unknown" for
+// containers that could not be inspected.
+var containerStatuses = map[string]bool{
+	"created":    true,
+	"running":    true,
+	"paused":     true,
+	"restarting": true,
+	"removing":   true,
+	"exited":     true,
+	"dead":       true,
+	"unknown":    true,
+}
+
+func ValidContainerStatus(status string) bool {
+	return containerStatuses[status]
+}
+
+// GetAllContainers lists the containers that are being tracked, leaving out
+// archived ones.
 func (s *SQLiteDB) GetAllContainers() ([]models.Container, error) {
+	return s.GetContainersSorted("addedAt", true, nil, false)
+}
+
+// GetContainersSorted lists containers in the given order, limited to the
+// given statuses when any are passed.
+func (s *SQLiteDB) GetContainersSorted(sort string, desc bool, statuses []string, includeArchived bool) ([]models.Container, error) {
+	column, ok := containerSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort column: %s", sort)
+	}
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := `SELECT id, container_id, container_name, alias, added_at, swapped_at, status, max_period, max_lines, server_name
-	          FROM containers ORDER BY added_at DESC`
+	var query strings.Builder
+	query.WriteString(`SELECT ` + containerColumns + ` FROM containers WHERE 1 = 1`)
+	args := make([]interface{}, 0, len(statuses))
+	if !includeArchived {
+		query.WriteString(` AND COALESCE(archived, 0) = 0`)
+	}
+	if len(statuses) > 0 {
+		query.WriteString(` AND status IN (?` + strings.Repeat(`, ?`, len(statuses)-1) + `)`)
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	query.WriteString(` ORDER BY ` + column + ` ` + direction + `, added_at DESC`)
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query.String(), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query containers: %w", err)
 	}
@@ -233,27 +791,11 @@ func (s *SQLiteDB) GetAllContainers() ([]models.Container, error) {
 
 	var containers []models.Container
 	for rows.Next() {
-		var c models.Container
-		var alias, serverName sql.NullString
-		var maxPeriod sql.NullInt64
-		var maxLines sql.NullInt64
-
-		if err := rows.Scan(
-			&c.ID, &c.ContainerID, &c.ContainerName, &alias, &c.AddedAt, &c.SwappedAt,
-			&c.Status, &maxPeriod, &maxLines, &serverName,
-		); err != nil {
+		c, err := scanContainer(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan container: %w", err)
 		}
 
-		c.Alias = alias.String
-		c.ServerName = serverName.String
-		if maxPeriod.Valid {
-			c.MaxPeriod = maxPeriod.Int64
-		}
-		if maxLines.Valid {
-			c.MaxLines = int(maxLines.Int64)
-		}
-
 		containers = append(containers, c)
 	}
 
@@ -272,103 +814,446 @@ func (s *SQLiteDB) UpdateContainerStatus(id string, status string) error {
 	return nil
 }
 
-func (s *SQLiteDB) UpdateContainerID(oldContainerID, newContainerID, newName string) error {
-	query := `UPDATE containers SET container_id = ?, container_name = ? WHERE container_id = ?`
-	_, err := s.db.Exec(query, newContainerID, newName, oldContainerID)
+func (s *SQLiteDB) SetStartedAt(id string, startedAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`UPDATE containers SET started_at = ? WHERE id = ?`, startedAt, id)
 	if err != nil {
-		return fmt.Errorf("failed to update container ID: %w", err)
+		return fmt.Errorf("failed to update started at: %w", err)
 	}
 	return nil
 }
 
-func (s *SQLiteDB) ResetAddedAt(containerID string) error {
-	query := `UPDATE containers SET added_at = ? WHERE container_id = ?`
-	_, err := s.db.Exec(query, time.Now().Unix(), containerID)
+func (s *SQLiteDB) SetCrashLooping(id string, looping bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`UPDATE containers SET crash_looping = ? WHERE id = ?`, looping, id)
 	if err != nil {
-		return fmt.Errorf("failed to reset added_at: %w", err)
+		return fmt.Errorf("failed to update crash loop state: %w", err)
 	}
 	return nil
 }
 
-func (s *SQLiteDB) RemoveContainer(id string) error {
-	query := `DELETE FROM containers WHERE id = ?`
-	_, err := s.db.Exec(query, id)
+// SetLogConfig records the logging driver configuration last read from
+// Docker for a container.
+func (s *SQLiteDB) SetLogConfig(id string, logConfig models.LogConfig) error {
+	data, err := json.Marshal(logConfig)
 	if err != nil {
-		return fmt.Errorf("failed to remove container: %w", err)
+		return fmt.Errorf("failed to encode log config: %w", err)
 	}
-	return nil
-}
 
-func (s *SQLiteDB) UpdateContainer(id string, containerName, alias, serverName string, maxPeriod int64, maxLines int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	query := `UPDATE containers SET container_name = ?, alias = ?, server_name = ?, max_period = ?, max_lines = ? WHERE id = ?`
-	_, err := s.db.Exec(query, containerName, alias, serverName, maxPeriod, maxLines, id)
-	if err != nil {
-		return fmt.Errorf("failed to update container: %w", err)
+	if _, err := s.db.Exec(`UPDATE containers SET log_config = ? WHERE id = ?`, string(data), id); err != nil {
+		return fmt.Errorf("failed to update log config: %w", err)
 	}
 	return nil
 }
 
-func (s *SQLiteDB) AddLog(ctx context.Context, logEntry *models.LogEntry) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if logEntry.ID == "" {
-		logEntry.ID = uuid.New().String()
+func (s *SQLiteDB) UpdateContainerID(oldContainerID, newContainerID, newName string) error {
+	query := `UPDATE containers SET container_id = ?, container_name = ? WHERE container_id = ?`
+	_, err := s.db.Exec(query, newContainerID, newName, oldContainerID)
+	if err != nil {
+		return fmt.Errorf("failed to update container ID: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteDB) RenameServer(oldName, newName string) (int64, error) {
+	result, err := s.db.Exec(`UPDATE containers SET server_name = ? WHERE server_name = ?`, newName, oldName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename server: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLiteDB) ResetAddedAt(containerID string) error {
+	query := `UPDATE containers SET added_at = ? WHERE container_id = ?`
+	_, err := s.db.Exec(query, time.Now().Unix(), containerID)
+	if err != nil {
+		return fmt.Errorf("failed to reset added_at: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteDB) RemoveContainer(id string) error {
+	query := `DELETE FROM containers WHERE id = ?`
+	_, err := s.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// archiveContainerID is the container_id an archived row is left with: the
+// Docker ID suffixed with the row's own id, so the column stays UNIQUE when the
+// same container is added again and lookups by Docker ID only find live rows.
+const archiveContainerID = `container_id || ':' || id`
+
+// ArchiveContainer stops tracking a container but keeps its row, so its logs
+// are not deleted along with it.
+func (s *SQLiteDB) ArchiveContainer(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`UPDATE containers SET archived = 1, container_id = `+archiveContainerID+` WHERE id = ? AND COALESCE(archived, 0) = 0`, id)
+	if err != nil {
+		return fmt.Errorf("failed to archive container: %w", err)
+	}
+	return nil
+}
+
+// RelinkContainer points an archived container at a new Docker container and
+// makes it active again, keeping its logs. It returns the last log timestamp
+// of the old container.
+func (s *SQLiteDB) RelinkContainer(id, newContainerID, newName string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin relink: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lastLogTs int64
+	err = tx.QueryRow(`SELECT COALESCE(last_log_timestamp, 0) FROM containers WHERE id = ?`, id).Scan(&lastLogTs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get container: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE containers SET container_id = ?, container_name = ?, swapped_at = ?, status = 'unknown', started_at = 0, archived = 0 WHERE id = ?`,
+		newContainerID, newName, time.Now().Unix(), id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to relink container: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE logs SET container_id = ? WHERE tracked_container_id = ?`, newContainerID, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to relink container logs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit relink: %w", err)
+	}
+
+	return lastLogTs, nil
+}
+
+func (s *SQLiteDB) UpdateContainer(id string, req *models.UpdateContainerRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return err
 	}
 
-	query := `INSERT OR IGNORE INTO logs (id, tracked_container_id, container_id, timestamp, message) VALUES (?, ?, ?, ?, ?)`
+	var detailKeys interface{}
+	if req.DetailKeys != nil {
+		detailKeys = strings.Join(req.DetailKeys, ",")
+	}
 
-	_, err := s.db.ExecContext(ctx, query, logEntry.ID, logEntry.TrackedContainerID, logEntry.ContainerID, logEntry.Timestamp, logEntry.Message)
+	var transforms interface{}
+	if req.Transforms != nil {
+		if transforms, err = encodeTransforms(req.Transforms); err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE containers SET container_name = ?, alias = ?, server_name = ?, max_period = ?, max_lines = ?,
+	          expected_max_gap = COALESCE(?, expected_max_gap), metadata = COALESCE(?, metadata),
+	          log_details = COALESCE(?, log_details), detail_keys = COALESCE(?, detail_keys),
+	          log_format = COALESCE(NULLIF(?, ''), log_format),
+	          collect_stdout = COALESCE(?, collect_stdout), collect_stderr = COALESCE(?, collect_stderr),
+	          initial_lines = COALESCE(?, initial_lines), sample_rate = COALESCE(?, sample_rate),
+	          json_ts_field = COALESCE(?, json_ts_field), json_ts_format = COALESCE(?, json_ts_format),
+	          json_message_field = COALESCE(?, json_message_field), json_level_field = COALESCE(?, json_level_field),
+	          transforms = COALESCE(?, transforms), inline_retention = COALESCE(?, inline_retention),
+	          syslog_forward = COALESCE(?, syslog_forward) WHERE id = ?`
+	_, err = s.db.Exec(query, req.ContainerName, req.Alias, req.ServerName, req.MaxPeriod, req.MaxLines, req.ExpectedMaxGap, metadata,
+		req.LogDetails, detailKeys, req.LogFormat, req.CollectStdout, req.CollectStderr, req.InitialLines, req.SampleRate,
+		req.JSONTimestampField, req.JSONTimestampFormat, req.JSONMessageField, req.JSONLevelField, transforms, req.InlineRetention, req.SyslogForward, id)
+	if err != nil {
+		return fmt.Errorf("failed to update container: %w", err)
+	}
+	return nil
+}
+
+// ErrDuplicateLog is returned by AddLog when the container already has a line
+// with the same timestamp and message.
+var ErrDuplicateLog = errors.New("log already stored")
+
+func (s *SQLiteDB) AddLog(ctx context.Context, logEntry *models.LogEntry) error {
+	if logEntry.ID == "" {
+		logEntry.ID = uuid.New().String()
+	}
+	s.lastInsert.Store(time.Now().UnixNano())
+
+	message, compressed, blob, err := s.encodeMessage(logEntry.Message)
+	if err != nil {
+		return fmt.Errorf("failed to compress log: %w", err)
+	}
+
+	details, err := encodeMetadata(logEntry.Details)
+	if err != nil {
+		return err
+	}
+
+	var fields interface{}
+	if logEntry.Fields != nil {
+		data, err := json.Marshal(logEntry.Fields)
+		if err != nil {
+			return fmt.Errorf("failed to encode fields: %w", err)
+		}
+		fields = string(data)
+	}
+
+	// The lock is taken per attempt so other writers and readers get in
+	// while a busy insert waits to retry.
+	return withBusyRetry(ctx, s.opts.BusyRetries, func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.insertLog(ctx, logEntry, message, compressed, blob, details, fields)
+	})
+}
+
+func (s *SQLiteDB) insertLog(ctx context.Context, logEntry *models.LogEntry, message string, compressed bool, blob []byte, details, fields interface{}) error {
+	query := `INSERT OR IGNORE INTO logs (id, tracked_container_id, container_id, timestamp, message, compressed, message_blob, level, overflow, details, occurrence, stream, fields) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if logEntry.Level == "" {
+		logEntry.Level = DetectLevel(logEntry.Message)
+	}
+	level := logEntry.Level
+
+	if s.opts.OverflowThreshold <= 0 || len(logEntry.Message) <= s.opts.OverflowThreshold {
+		result, err := s.db.ExecContext(ctx, query, logEntry.ID, logEntry.TrackedContainerID, logEntry.ContainerID, logEntry.Timestamp, message, compressed, blob, level, false, details, logEntry.Occurrence, logEntry.Stream, fields)
+		if err != nil {
+			return fmt.Errorf("failed to add log: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrDuplicateLog
+		}
+		logEntry.Seq, _ = result.LastInsertId()
+		return nil
+	}
+
+	if !compressed {
+		blob = []byte(message)
+		message = messageDigest("large:", logEntry.Message)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add log: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, logEntry.ID, logEntry.TrackedContainerID, logEntry.ContainerID, logEntry.Timestamp, message, compressed, nil, level, true, details, logEntry.Occurrence, logEntry.Stream, fields)
 	if err != nil {
 		return fmt.Errorf("failed to add log: %w", err)
 	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDuplicateLog
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO large_logs (id, message_blob) VALUES (?, ?)`, logEntry.ID, blob); err != nil {
+		return fmt.Errorf("failed to add large log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to add log: %w", err)
+	}
+	logEntry.Seq, _ = result.LastInsertId()
 	return nil
 }
 
+// IsDiskFull reports whether err means the database could not grow, either
+// SQLITE_FULL or an I/O error caused by the filesystem running out of space.
+func IsDiskFull(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrFull || sqliteErr.SystemErrno == syscall.ENOSPC
+	}
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// ProbeWrite checks whether the database can take new rows by writing a
+// throwaway row that is always rolled back.
+func (s *SQLiteDB) ProbeWrite(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin probe: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO large_logs (id, message_blob) VALUES (?, zeroblob(65536))`, "probe:"+uuid.New().String()); err != nil {
+		return fmt.Errorf("failed to write probe: %w", err)
+	}
+	return nil
+}
+
+// GetLastLogTimestamp returns the newest stream timestamp stored for a
+// container, including updates that are still cached.
 func (s *SQLiteDB) GetLastLogTimestamp(trackedContainerID string) (int64, error) {
+	s.lastLogMu.Lock()
+	pending := s.pendingLastLog[trackedContainerID]
+	s.lastLogMu.Unlock()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var timestamp int64
-	err := s.db.QueryRow(`SELECT last_log_timestamp FROM containers WHERE id = ?`, trackedContainerID).Scan(&timestamp)
+	err := s.db.QueryRow(`SELECT COALESCE(last_log_timestamp, 0) FROM containers WHERE id = ?`, trackedContainerID).Scan(&timestamp)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return 0, nil
+			return pending, nil
 		}
 		return 0, err
 	}
-	return timestamp, nil
+	return max(timestamp, pending), nil
 }
 
+// UpdateLastLogTimestamp moves a container's last_log_timestamp forward. Both
+// the collector and live streams report to it, so an older timestamp (e.g.
+// from a stream replaying history) never moves it back.
 func (s *SQLiteDB) UpdateLastLogTimestamp(trackedContainerID string, timestamp int64) error {
+	if s.opts.LastLogFlushInterval > 0 {
+		s.lastLogMu.Lock()
+		s.pendingLastLog[trackedContainerID] = max(s.pendingLastLog[trackedContainerID], timestamp)
+		s.lastLogMu.Unlock()
+		return nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.Exec(`UPDATE containers SET last_log_timestamp = ? WHERE id = ?`, timestamp, trackedContainerID)
+	_, err := s.db.Exec(`UPDATE containers SET last_log_timestamp = MAX(COALESCE(last_log_timestamp, 0), ?) WHERE id = ?`, timestamp, trackedContainerID)
 	return err
 }
 
-func (s *SQLiteDB) GetLogs(trackedContainerID string, limit int, before *time.Time) ([]models.LogEntry, error) {
+// GetLogs returns the logs matching q, newest first.
+func (s *SQLiteDB) GetLogs(q LogQuery) ([]models.LogEntry, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var query strings.Builder
-	query.WriteString(`SELECT id, container_id, timestamp, message FROM logs WHERE tracked_container_id = ?`)
+	where, args := q.where()
+	query := `SELECT ` + logColumns + ` FROM ` + logSource + ` WHERE ` + where + ` ORDER BY timestamp DESC, logs.rowid DESC LIMIT ?`
+	return queryLogs(s.db, query, append(args, q.Limit)...)
+}
 
-	args := []interface{}{trackedContainerID}
+// CountLogs counts the logs matching q, ignoring its limit.
+func (s *SQLiteDB) CountLogs(q LogQuery) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if before != nil {
-		query.WriteString(` AND timestamp < ?`)
-		args = append(args, before.UnixNano())
+	query, args := q.countQuery()
+	var count int
+	err := s.db.QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
 	}
+	return count, nil
+}
 
-	query.WriteString(` ORDER BY timestamp DESC LIMIT ?`)
+// GetMergedLogs returns the newest logs of several containers interleaved by
+// timestamp, newest first. Each container's slice is read through its own
+// index range and the slices are combined with UNION ALL, so the cost does not
+// depend on how many logs the other containers have.
+func (s *SQLiteDB) GetMergedLogs(trackedContainerIDs []string, limit int, before *time.Time) ([]models.LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	parts := make([]string, 0, len(trackedContainerIDs))
+	args := make([]interface{}, 0, 3*len(trackedContainerIDs)+1)
+	for _, id := range trackedContainerIDs {
+		part := `SELECT * FROM (SELECT logs.rowid AS seq, tracked_container_id, ` + logColumns + ` FROM ` + logSource + ` WHERE tracked_container_id = ?`
+		args = append(args, id)
+		if before != nil {
+			part += ` AND timestamp < ?`
+			args = append(args, before.UnixNano())
+		}
+		parts = append(parts, part+` ORDER BY timestamp DESC, logs.rowid DESC LIMIT ?)`)
+		args = append(args, limit)
+	}
+	query := `SELECT * FROM (` + strings.Join(parts, ` UNION ALL `) + `) ORDER BY timestamp DESC, seq DESC LIMIT ?`
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query.String(), args...)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merged logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []models.LogEntry
+	for rows.Next() {
+		var seq int64
+		var trackedID string
+		l, err := scanLogEntry(trackedScanner{row: seqScanner{row: rows, seq: &seq}, trackedID: &trackedID})
+		if err != nil {
+			return nil, err
+		}
+		l.TrackedContainerID = trackedID
+		logs = append(logs, l)
+	}
+
+	return logs, rows.Err()
+}
+
+const logColumns = `logs.id, container_id, timestamp, message, compressed, COALESCE(large_logs.message_blob, logs.message_blob), details, pinned,
+	COALESCE(level, ''), COALESCE(stream, ''), fields`
+
+// logSource joins overflowed messages in; the lookup only runs for rows the
+// query actually returns.
+const logSource = `logs LEFT JOIN large_logs ON large_logs.id = logs.id`
+
+func scanLogEntry(row rowScanner) (models.LogEntry, error) {
+	var l models.LogEntry
+	var compressed bool
+	var blob []byte
+	var details, fields sql.NullString
+
+	if err := row.Scan(&l.ID, &l.ContainerID, &l.Timestamp, &l.Message, &compressed, &blob, &details, &l.Pinned,
+		&l.Level, &l.Stream, &fields); err != nil {
+		return l, fmt.Errorf("failed to scan log: %w", err)
+	}
+
+	if details.String != "" {
+		if err := json.Unmarshal([]byte(details.String), &l.Details); err != nil {
+			return l, fmt.Errorf("failed to decode details of log %s: %w", l.ID, err)
+		}
+	}
+
+	if fields.String != "" {
+		// Keep numbers as written rather than rounding them through float64.
+		decoder := json.NewDecoder(strings.NewReader(fields.String))
+		decoder.UseNumber()
+		if err := decoder.Decode(&l.Fields); err != nil {
+			return l, fmt.Errorf("failed to decode fields of log %s: %w", l.ID, err)
+		}
+	}
+
+	if compressed {
+		message, err := decompressMessage(blob)
+		if err != nil {
+			return l, fmt.Errorf("failed to decompress log %s: %w", l.ID, err)
+		}
+		l.Message = message
+	} else if blob != nil {
+		l.Message = string(blob)
+	}
+
+	return l, nil
+}
+
+func queryLogs(db *sql.DB, query string, args ...interface{}) ([]models.LogEntry, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query logs: %w", err)
 	}
@@ -376,32 +1261,314 @@ func (s *SQLiteDB) GetLogs(trackedContainerID string, limit int, before *time.Ti
 
 	logs := make([]models.LogEntry, 0)
 	for rows.Next() {
-		var l models.LogEntry
+		l, err := scanLogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+
+	return logs, rows.Err()
+}
+
+// SetLogPinned pins or unpins a log of trackedContainerID and reports whether
+// the log exists. Pinned logs are never removed by retention.
+func (s *SQLiteDB) SetLogPinned(trackedContainerID, logID string, pinned bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`UPDATE logs SET pinned = ? WHERE id = ? AND tracked_container_id = ?`, pinned, logID, trackedContainerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to pin log: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// AddAnnotation attaches a note to a log of trackedContainerID. It returns nil
+// when the log does not exist. Annotated logs are never removed by retention.
+func (s *SQLiteDB) AddAnnotation(trackedContainerID, logID, note, author string) (*models.Annotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := models.Annotation{
+		ID:        uuid.New().String(),
+		LogID:     logID,
+		Note:      note,
+		Author:    author,
+		CreatedAt: time.Now().Unix(),
+	}
+	result, err := s.db.Exec(`INSERT INTO annotations (id, log_id, tracked_container_id, note, author, created_at)
+		SELECT ?, id, tracked_container_id, ?, ?, ? FROM logs WHERE id = ? AND tracked_container_id = ?`,
+		a.ID, a.Note, a.Author, a.CreatedAt, logID, trackedContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add annotation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// GetAnnotations returns the annotations of the given logs keyed by log ID,
+// oldest first.
+func (s *SQLiteDB) GetAnnotations(logIDs []string) (map[string][]models.Annotation, error) {
+	annotations := make(map[string][]models.Annotation)
+	if len(logIDs) == 0 {
+		return annotations, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	args := make([]interface{}, len(logIDs))
+	for i, id := range logIDs {
+		args[i] = id
+	}
+	rows, err := s.db.Query(`SELECT id, log_id, note, author, created_at FROM annotations
+		WHERE log_id IN (?`+strings.Repeat(`, ?`, len(logIDs)-1)+`) ORDER BY created_at, rowid`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.Annotation
+		if err := rows.Scan(&a.ID, &a.LogID, &a.Note, &a.Author, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan annotation: %w", err)
+		}
+		annotations[a.LogID] = append(annotations[a.LogID], a)
+	}
+	return annotations, rows.Err()
+}
+
+// GetLogContext returns up to before logs preceding logID and up to after
+// logs following it, oldest first, with the index of logID in the result.
+// It returns nil if logID is not a log of trackedContainerID.
+func (s *SQLiteDB) GetLogContext(trackedContainerID, logID string, before, after int) ([]models.LogEntry, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var timestamp, rowid int64
+	err := s.db.QueryRow(`SELECT timestamp, rowid FROM logs WHERE id = ? AND tracked_container_id = ?`, logID, trackedContainerID).Scan(&timestamp, &rowid)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find log: %w", err)
+	}
+
+	preceding, err := queryLogs(s.db, `SELECT `+logColumns+` FROM `+logSource+` WHERE tracked_container_id = ?
+		AND (timestamp < ? OR (timestamp = ? AND logs.rowid < ?)) ORDER BY timestamp DESC, logs.rowid DESC LIMIT ?`,
+		trackedContainerID, timestamp, timestamp, rowid, before)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	following, err := queryLogs(s.db, `SELECT `+logColumns+` FROM `+logSource+` WHERE tracked_container_id = ?
+		AND (timestamp > ? OR (timestamp = ? AND logs.rowid >= ?)) ORDER BY timestamp ASC, logs.rowid ASC LIMIT ?`,
+		trackedContainerID, timestamp, timestamp, rowid, after+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.Reverse(preceding)
+	return append(preceding, following...), len(preceding), nil
+}
+
+// GetLogsAfterSeq returns logs stored after seq, oldest first. Sequence
+// numbers are logs rowids, which only grow while the newest row is kept.
+func (s *SQLiteDB) GetLogsAfterSeq(trackedContainerID string, afterSeq int64, limit int) ([]models.LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		if err := rows.Scan(&l.ID, &l.ContainerID, &l.Timestamp, &l.Message); err != nil {
-			return nil, fmt.Errorf("failed to scan log: %w", err)
+	return querySeqLogs(s.db, `SELECT logs.rowid, `+logColumns+` FROM `+logSource+`
+		WHERE tracked_container_id = ? AND logs.rowid > ? ORDER BY logs.rowid ASC LIMIT ?`,
+		trackedContainerID, afterSeq, limit)
+}
+
+// ExportLogs passes every log matching q to fn, oldest first. It reads one
+// page at a time so a long export never holds the database lock for long.
+func (s *SQLiteDB) ExportLogs(ctx context.Context, q LogQuery, fn func(models.LogEntry) error) error {
+	where, args := q.where()
+	query := `SELECT logs.rowid, ` + logColumns + ` FROM ` + logSource + ` WHERE ` + where +
+		` AND (timestamp > ? OR (timestamp = ? AND logs.rowid > ?)) ORDER BY timestamp ASC, logs.rowid ASC LIMIT ?`
+
+	var lastTimestamp int64 = math.MinInt64
+	var lastSeq int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		logs, err := querySeqLogs(s.db, query, append(args, lastTimestamp, lastTimestamp, lastSeq, exportPageSize)...)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		for _, l := range logs {
+			if err := fn(l); err != nil {
+				return err
+			}
+		}
+		if len(logs) < exportPageSize {
+			return nil
 		}
+		lastTimestamp = logs[len(logs)-1].Timestamp
+		lastSeq = logs[len(logs)-1].Seq
+	}
+}
 
+func querySeqLogs(db *sql.DB, query string, args ...interface{}) ([]models.LogEntry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := make([]models.LogEntry, 0)
+	for rows.Next() {
+		var seq int64
+		l, err := scanLogEntry(seqScanner{rows, &seq})
+		if err != nil {
+			return nil, err
+		}
+		l.Seq = seq
 		logs = append(logs, l)
 	}
 
-	return logs, nil
+	return logs, rows.Err()
 }
 
-func (s *SQLiteDB) GetLogCount(trackedContainerID string) (int, error) {
-	query := `SELECT COUNT(*) FROM logs WHERE tracked_container_id = ?`
-	var count int
-	err := s.db.QueryRow(query, trackedContainerID).Scan(&count)
+func (s *SQLiteDB) GetLatestSeq(trackedContainerID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var seq int64
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(rowid), 0) FROM logs WHERE tracked_container_id = ?`, trackedContainerID).Scan(&seq)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count logs: %w", err)
+		return 0, fmt.Errorf("failed to get latest seq: %w", err)
 	}
-	return count, nil
+	return seq, nil
+}
+
+// GetNewestLogTimestamp returns the timestamp of the newest stored log, or 0
+// when the container has none.
+func (s *SQLiteDB) GetNewestLogTimestamp(trackedContainerID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var timestamp int64
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(timestamp), 0) FROM logs WHERE tracked_container_id = ?`, trackedContainerID).Scan(&timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get newest log timestamp: %w", err)
+	}
+	return timestamp, nil
+}
+
+type seqScanner struct {
+	row rowScanner
+	seq *int64
+}
+
+func (s seqScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.seq}, dest...)...)
+}
+
+type trackedScanner struct {
+	row       rowScanner
+	trackedID *string
+}
+
+func (s trackedScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.trackedID}, dest...)...)
+}
+
+func (s *SQLiteDB) GetDistinctLevels(trackedContainerID string) ([]models.LevelCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT level, COUNT(*) FROM logs WHERE tracked_container_id = ? AND level != '' GROUP BY level ORDER BY level`
+	rows, err := s.db.Query(query, trackedContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.LevelCount{}
+	for rows.Next() {
+		var lc models.LevelCount
+		if err := rows.Scan(&lc.Level, &lc.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan level: %w", err)
+		}
+		levels = append(levels, lc)
+	}
+
+	return levels, rows.Err()
 }
 
 func (s *SQLiteDB) RetentionManager() *RetentionManager {
 	return s.retention
 }
 
+// GetContainerSizes returns the estimated stored log bytes per container. It
+// scans every log, so the result is cached and refreshed after each retention
+// pass rather than computed per call.
+func (s *SQLiteDB) GetContainerSizes() (map[string]int64, error) {
+	s.sizesMu.Lock()
+	sizes := s.sizes
+	s.sizesMu.Unlock()
+	if sizes != nil {
+		return sizes, nil
+	}
+	return s.refreshContainerSizes()
+}
+
+func (s *SQLiteDB) refreshContainerSizes() (map[string]int64, error) {
+	sizes, err := s.containerSizes()
+	if err != nil {
+		return nil, err
+	}
+	s.sizesMu.Lock()
+	s.sizes = sizes
+	s.sizesMu.Unlock()
+	return sizes, nil
+}
+
+func (s *SQLiteDB) containerSizes() (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT tracked_container_id,
+	          SUM(CASE WHEN compressed = 1 OR overflow = 1 THEN LENGTH(COALESCE(large_logs.message_blob, logs.message_blob)) ELSE LENGTH(message) END)
+	          FROM ` + logSource + ` GROUP BY tracked_container_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query container sizes: %w", err)
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]int64)
+	for rows.Next() {
+		var id string
+		var size sql.NullInt64
+		if err := rows.Scan(&id, &size); err != nil {
+			return nil, fmt.Errorf("failed to scan container size: %w", err)
+		}
+		sizes[id] = size.Int64
+	}
+
+	return sizes, rows.Err()
+}
+
+func (s *SQLiteDB) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
 func (s *SQLiteDB) DB() *sql.DB {
 	return s.db
 }
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestEmergencyPruneFreesSpaceWhilePaused(t *testing.T) {
+	// One connection, so max_page_count applies to every statement.
+	s := newTestDB(t, Options{MaxOpenConns: 1, MaxIdleConns: 1, BusyTimeout: time.Second})
+	c := addTestContainer(t, s, "docker")
+	ctx := context.Background()
+
+	line := strings.Repeat("x", 1024)
+	insert := func(i int) error {
+		return s.AddLog(ctx, &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: int64(i + 1), Message: fmt.Sprintf("%d %s", i, line)})
+	}
+	for i := 0; i < 200; i++ {
+		if err := insert(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pages int
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(fmt.Sprintf(`PRAGMA max_page_count = %d`, pages)); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	next := 200
+	for ; next < 1000 && err == nil; next++ {
+		err = insert(next)
+	}
+	if !IsDiskFull(err) {
+		t.Fatalf("insert into a full database: %v, want SQLITE_FULL", err)
+	}
+
+	s.RetentionManager().Pause()
+	removed, err := s.RetentionManager().EmergencyPrune(ctx, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed == 0 {
+		t.Fatal("emergency prune removed nothing while retention was paused")
+	}
+	if err := insert(next); err != nil {
+		t.Fatalf("insert after the emergency prune: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func newTestDB(t testing.TB, opts Options) *SQLiteDB {
+	t.Helper()
+	s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "logs.db"), opts)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func addTestContainer(t testing.TB, s *SQLiteDB, containerID string) *models.Container {
+	t.Helper()
+	c, err := s.AddContainer(&models.AddContainerRequest{Name: containerID}, containerID, containerID, "test")
+	if err != nil {
+		t.Fatalf("add container: %v", err)
+	}
+	return c
+}
+
+func addTestLog(t testing.TB, s *SQLiteDB, c *models.Container, timestamp int64, message string) string {
+	t.Helper()
+	entry := &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: timestamp, Message: message}
+	if err := s.AddLog(context.Background(), entry); err != nil {
+		t.Fatalf("add log: %v", err)
+	}
+	return entry.ID
+}
+
+// testMessages returns the messages stored for c, oldest first.
+func testMessages(t testing.TB, s *SQLiteDB, c *models.Container) []string {
+	t.Helper()
+	logs, err := s.GetLogs(LogQuery{TrackedContainerID: c.ID, Limit: 1000})
+	if err != nil {
+		t.Fatalf("get logs: %v", err)
+	}
+	messages := make([]string, len(logs))
+	for i, entry := range logs {
+		messages[len(logs)-1-i] = entry.Message
+	}
+	return messages
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+func (s *SQLiteDB) lastLogFlushLoop(interval time.Duration) {
+	defer close(s.flushDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopFlush:
+			if err := s.FlushLastLogTimestamps(); err != nil {
+				log.Printf("[backend] Failed to flush last log timestamps on shutdown: %v", err)
+			}
+			return
+		}
+		if err := s.FlushLastLogTimestamps(); err != nil {
+			log.Printf("[backend] Failed to flush last log timestamps: %v", err)
+		}
+	}
+}
+
+// FlushLastLogTimestamps writes cached last_log_timestamp updates in one
+// transaction. Updates that fail to write stay cached for the next flush
+// unless a newer one arrived meanwhile.
+func (s *SQLiteDB) FlushLastLogTimestamps() error {
+	s.lastLogMu.Lock()
+	pending := s.pendingLastLog
+	s.pendingLastLog = make(map[string]int64, len(pending))
+	s.lastLogMu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := s.writeLastLogTimestamps(pending)
+	if err != nil {
+		s.lastLogMu.Lock()
+		for id, timestamp := range pending {
+			s.pendingLastLog[id] = max(s.pendingLastLog[id], timestamp)
+		}
+		s.lastLogMu.Unlock()
+	}
+	return err
+}
+
+func (s *SQLiteDB) writeLastLogTimestamps(timestamps map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin flush: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE containers SET last_log_timestamp = MAX(COALESCE(last_log_timestamp, 0), ?) WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare flush: %w", err)
+	}
+	defer stmt.Close()
+
+	for id, timestamp := range timestamps {
+		if _, err := stmt.Exec(timestamp, id); err != nil {
+			return fmt.Errorf("failed to update last log timestamp: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit flush: %w", err)
+	}
+	return nil
+}
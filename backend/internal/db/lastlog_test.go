@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// storedLastLog reads last_log_timestamp from the file through a separate
+// connection, bypassing s's cache, as a restarted process would.
+func storedLastLog(t *testing.T, path, trackedContainerID string) int64 {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var timestamp sql.NullInt64
+	if err := conn.QueryRow(`SELECT last_log_timestamp FROM containers WHERE id = ?`, trackedContainerID).Scan(&timestamp); err != nil {
+		t.Fatal(err)
+	}
+	return timestamp.Int64
+}
+
+func TestLastLogTimestampFlushedOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewSQLiteDB(path, Options{LastLogFlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := addTestContainer(t, s, "docker")
+	added := storedLastLog(t, path, c.ID)
+	if err := s.UpdateLastLogTimestamp(c.ID, added+100); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedLastLog(t, path, c.ID); got != added {
+		t.Fatalf("stored before the flush = %d, want %d", got, added)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedLastLog(t, path, c.ID); got != added+100 {
+		t.Fatalf("stored after Close = %d, want %d", got, added+100)
+	}
+}
+
+func TestLastLogTimestampLosesAtMostOneInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	interval := 50 * time.Millisecond
+	s, err := NewSQLiteDB(path, Options{LastLogFlushInterval: interval})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := addTestContainer(t, s, "docker")
+	added := storedLastLog(t, path, c.ID)
+
+	// Without Close, as after a crash, an update reaches the file within an
+	// interval of being made.
+	for _, timestamp := range []int64{added + 100, added + 200} {
+		if err := s.UpdateLastLogTimestamp(c.ID, timestamp); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(interval + time.Second)
+		for storedLastLog(t, path, c.ID) != timestamp {
+			if time.Now().After(deadline) {
+				t.Fatalf("timestamp %d not stored within an interval", timestamp)
+			}
+			time.Sleep(interval / 5)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	errorLevelPattern = regexp.MustCompile(`\b(ERR|ERROR)\b`)
+	warnLevelPattern  = regexp.MustCompile(`\b(WARN|WARNING)\b`)
+	debugLevelPattern = regexp.MustCompile(`\b(DEBUG|DBG)\b`)
+)
+
+// DetectLevel mirrors the frontend's getLogLevel so stored levels match what
+// the viewer shows.
+func DetectLevel(message string) string {
+	msg := strings.ToUpper(message)
+	switch {
+	case strings.Contains(msg, "[SYSTEM]"):
+		return "SYSTEM"
+	case errorLevelPattern.MatchString(msg):
+		return "ERROR"
+	case warnLevelPattern.MatchString(msg):
+		return "WARN"
+	case debugLevelPattern.MatchString(msg):
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
+
+var logLevels = map[string]bool{"ERROR": true, "WARN": true, "INFO": true, "DEBUG": true, "SYSTEM": true}
+
+// ValidLogLevel reports whether level is one of the upper-case levels stored
+// with each log.
+func ValidLogLevel(level string) bool {
+	return logLevels[level]
+}
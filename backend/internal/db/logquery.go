@@ -0,0 +1,84 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// LogQuery selects logs of one container. Unset fields do not filter, and the
+// filters that are set all have to match.
+type LogQuery struct {
+	TrackedContainerID string
+	// Since is inclusive and Until exclusive; nil bounds are open.
+	Since *time.Time
+	Until *time.Time
+	// Levels are the stored upper-case levels, see ValidLogLevel.
+	Levels     []string
+	PinnedOnly bool
+	// Contains matches messages case-insensitively (ASCII only). Compressed
+	// messages are decompressed to be searched, after the other filters.
+	Contains string
+	Limit    int
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// where builds the filter for q against logSource.
+func (q LogQuery) where() (string, []interface{}) {
+	var where strings.Builder
+	where.WriteString(`tracked_container_id = ?`)
+	args := []interface{}{q.TrackedContainerID}
+
+	if q.PinnedOnly {
+		where.WriteString(` AND pinned = 1`)
+	}
+	if q.Since != nil {
+		where.WriteString(` AND timestamp >= ?`)
+		args = append(args, q.Since.UnixNano())
+	}
+	if q.Until != nil {
+		where.WriteString(` AND timestamp < ?`)
+		args = append(args, q.Until.UnixNano())
+	}
+	if len(q.Levels) > 0 {
+		where.WriteString(` AND level IN (?` + strings.Repeat(`, ?`, len(q.Levels)-1) + `)`)
+		for _, level := range q.Levels {
+			args = append(args, level)
+		}
+	}
+	if q.Contains != "" {
+		// Compressed and overflowed messages keep only a digest in
+		// logs.message. The compressed bytes are in large_logs if the message
+		// also overflowed, and in logs.message_blob otherwise.
+		pattern := "%" + likeEscaper.Replace(q.Contains) + "%"
+		where.WriteString(` AND (CASE WHEN compressed THEN zlib_text(COALESCE(large_logs.message_blob, logs.message_blob)) LIKE ? ESCAPE '\' WHEN overflow THEN CAST(large_logs.message_blob AS TEXT) LIKE ? ESCAPE '\' ELSE logs.message LIKE ? ESCAPE '\' END)`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	return where.String(), args
+}
+
+// countQuery is the statement CountLogs runs. INDEXED BY makes it fail rather
+// than fall back to a full scan if the index is missing, which the migrations
+// guard against by failing when an index cannot be created.
+func (q LogQuery) countQuery() (string, []interface{}) {
+	where, args := q.where()
+	source := `logs INDEXED BY ` + q.countIndex()
+	if q.Contains != "" {
+		source += ` LEFT JOIN large_logs ON large_logs.id = logs.id`
+	}
+	return `SELECT COUNT(*) FROM ` + source + ` WHERE ` + where, args
+}
+
+// countIndex picks the index CountLogs reads through. Left to itself the
+// planner counts through the unique index, which also holds every message and
+// is far larger than the others.
+func (q LogQuery) countIndex() string {
+	switch {
+	case q.PinnedOnly:
+		return "idx_logs_pinned"
+	case len(q.Levels) > 0 && q.Since == nil && q.Until == nil:
+		return "idx_logs_container_level"
+	default:
+		return "idx_logs_container_timestamp"
+	}
+}
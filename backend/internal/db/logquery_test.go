@@ -0,0 +1,51 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountLogsQueryPlan(t *testing.T) {
+	s := newTestDB(t, Options{})
+	c := addTestContainer(t, s, "docker")
+	since := time.Now().Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name  string
+		query LogQuery
+		index string
+	}{
+		{"all", LogQuery{TrackedContainerID: c.ID}, "idx_logs_container_timestamp"},
+		{"since", LogQuery{TrackedContainerID: c.ID, Since: &since}, "idx_logs_container_timestamp"},
+		{"search", LogQuery{TrackedContainerID: c.ID, Contains: "error"}, "idx_logs_container_timestamp"},
+		{"levels", LogQuery{TrackedContainerID: c.ID, Levels: []string{"ERROR"}}, "idx_logs_container_level"},
+		{"pinned", LogQuery{TrackedContainerID: c.ID, PinnedOnly: true}, "idx_logs_pinned"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args := tc.query.countQuery()
+			rows, err := s.db.Query(`EXPLAIN QUERY PLAN `+query, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatal(err)
+				}
+				plan = append(plan, detail)
+			}
+			if joined := strings.Join(plan, "\n"); !strings.Contains(joined, "INDEX "+tc.index+" ") && !strings.HasSuffix(joined, "INDEX "+tc.index) {
+				t.Fatalf("plan %q does not use %s", plan, tc.index)
+			}
+
+			if _, err := s.CountLogs(tc.query); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOccurrenceMigrationKeepsSeq(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := addTestContainer(t, s, "docker")
+	s.Close()
+
+	// Put back a logs table as created before the occurrence column, with
+	// gaps in its rowids left by retention.
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`DROP TABLE logs`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`CREATE TABLE logs (
+		id TEXT PRIMARY KEY,
+		tracked_container_id TEXT NOT NULL,
+		container_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		message TEXT NOT NULL,
+		compressed INTEGER DEFAULT 0,
+		message_blob BLOB,
+		level TEXT DEFAULT '',
+		overflow INTEGER DEFAULT 0,
+		details TEXT,
+		pinned INTEGER DEFAULT 0,
+		UNIQUE (tracked_container_id, timestamp, message)
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`INSERT INTO logs (rowid, id, tracked_container_id, container_id, timestamp, message) VALUES
+		(1000, 'a', ?, 'docker', 1, 'first'),
+		(2500, 'b', ?, 'docker', 2, 'second')`, c.ID, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	s, err = NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	defer s.Close()
+
+	logs, err := s.GetLogsAfterSeq(c.ID, 1000, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Message != "second" || logs[0].Seq != 2500 {
+		t.Fatalf("logs after seq 1000 = %+v, want only second with seq 2500", logs)
+	}
+
+	latest, err := s.GetLatestSeq(c.ID)
+	if err != nil || latest != 2500 {
+		t.Fatalf("latest seq = %d, %v, want 2500", latest, err)
+	}
+}
+
+func TestMaxPeriodDaysMigrationKeepsLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	old := addTestContainer(t, s, "old")
+	recent := addTestContainer(t, s, "recent")
+	unlimited := addTestContainer(t, s, "unlimited")
+	addTestLog(t, s, old, now.Add(-50*time.Hour).UnixNano(), "two days old")
+	addTestLog(t, s, old, now.Add(-time.Hour).UnixNano(), "an hour old")
+	addTestLog(t, s, recent, now.Add(-time.Hour).UnixNano(), "an hour old")
+	addTestLog(t, s, unlimited, now.Add(-50*time.Hour).UnixNano(), "two days old")
+	for id, maxPeriod := range map[string]int64{old.ID: 1, recent.ID: 7} {
+		if _, err := s.db.Exec(`UPDATE containers SET max_period = ? WHERE id = ?`, maxPeriod, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Go back to a containers table from before maxPeriod was in days.
+	if _, err := s.db.Exec(`ALTER TABLE containers DROP COLUMN max_period_in_days`); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	for id, want := range map[string]int64{old.ID: 3, recent.ID: 7, unlimited.ID: 0} {
+		var maxPeriod int64
+		if err := s.db.QueryRow(`SELECT max_period FROM containers WHERE id = ?`, id).Scan(&maxPeriod); err != nil {
+			t.Fatal(err)
+		}
+		if maxPeriod != want {
+			t.Fatalf("maxPeriod of %s = %d, want %d", id, maxPeriod, want)
+		}
+	}
+
+	if err := s.RetentionManager().applyRetentionPolicies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, old); fmt.Sprint(got) != "[two days old an hour old]" {
+		t.Fatalf("retention after upgrading kept %v, want every log", got)
+	}
+
+	// The migration runs once, so a lowered maxPeriod stays as set.
+	if _, err := s.db.Exec(`UPDATE containers SET max_period = 1 WHERE id = ?`, old.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s, err = NewSQLiteDB(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var maxPeriod int64
+	if err := s.db.QueryRow(`SELECT max_period FROM containers WHERE id = ?`, old.ID).Scan(&maxPeriod); err != nil || maxPeriod != 1 {
+		t.Fatalf("maxPeriod after reopening = %d, %v, want 1", maxPeriod, err)
+	}
+}
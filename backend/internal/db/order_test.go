@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSameTimestampLogsKeepInsertOrder(t *testing.T) {
+	s := newTestDB(t, Options{})
+	c := addTestContainer(t, s, "docker")
+	const n = 50
+	for i := 0; i < n; i++ {
+		addTestLog(t, s, c, 1000, fmt.Sprintf("line %02d", i))
+	}
+
+	for i, message := range testMessages(t, s, c) {
+		if want := fmt.Sprintf("line %02d", i); message != want {
+			t.Fatalf("message %d = %q, want %q", i, message, want)
+		}
+	}
+
+	// Retention removes the earliest inserted of the tied lines first.
+	if _, err := s.RetentionManager().ApplyRetentionForContainer(context.Background(), c.ID, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	got := testMessages(t, s, c)
+	if len(got) != 10 || got[0] != "line 40" || got[9] != "line 49" {
+		t.Fatalf("kept %q, want lines 40 to 49", got)
+	}
+}
@@ -5,23 +5,80 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const (
+	pruneBatchSize        = 500
+	orphanCleanupInterval = time.Hour
 )
 
+type Archiver interface {
+	Archive(ctx context.Context, trackedContainerID string, logs []models.LogEntry) error
+}
+
 type RetentionManager struct {
-	db       *sql.DB
-	stopChan chan struct{}
-	doneChan chan struct{}
+	db              *sql.DB
+	writeMu         sync.Locker
+	busyRetries     int
+	archiver        Archiver
+	archiveRequired bool
+	absoluteMaxAge  time.Duration
+	paused          atomic.Bool
+	stopChan        chan struct{}
+	doneChan        chan struct{}
+	// afterPass runs after every periodic pass.
+	afterPass func()
 }
 
-func NewRetentionManager(db *sql.DB) *RetentionManager {
+// NewRetentionManager deletes through db while holding writeMu, the lock
+// inserts take, one batch at a time so pruning never stalls ingestion.
+func NewRetentionManager(db *sql.DB, writeMu sync.Locker) *RetentionManager {
 	return &RetentionManager{
 		db:       db,
+		writeMu:  writeMu,
 		stopChan: make(chan struct{}),
 		doneChan: make(chan struct{}),
 	}
 }
 
+// SetArchiver exports logs through archiver before retention deletes them.
+// When required is set, a failed export leaves the logs in place.
+func (r *RetentionManager) SetArchiver(archiver Archiver, required bool) {
+	r.archiver = archiver
+	r.archiveRequired = required
+}
+
+// SetAbsoluteMaxAge makes every retention pass delete logs older than maxAge
+// from all containers, on top of their own limits and including containers
+// without any. Zero disables it.
+func (r *RetentionManager) SetAbsoluteMaxAge(maxAge time.Duration) {
+	r.absoluteMaxAge = maxAge
+}
+
+// Pause stops retention deletes, both periodic and inline, until Resume; only
+// EmergencyPrune still runs.
+func (r *RetentionManager) Pause() {
+	if !r.paused.Swap(true) {
+		log.Printf("[backend] Retention paused")
+	}
+}
+
+func (r *RetentionManager) Resume() {
+	if r.paused.Swap(false) {
+		log.Printf("[backend] Retention resumed")
+	}
+}
+
+func (r *RetentionManager) Paused() bool {
+	return r.paused.Load()
+}
+
 func (r *RetentionManager) Start(ctx context.Context, interval time.Duration) {
 	go r.run(ctx, interval)
 }
@@ -37,6 +94,7 @@ func (r *RetentionManager) run(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	lastOrphanCleanup := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
@@ -44,44 +102,140 @@ func (r *RetentionManager) run(ctx context.Context, interval time.Duration) {
 		case <-r.stopChan:
 			return
 		case <-ticker.C:
-			if err := r.applyRetentionPolicies(ctx); err != nil {
-				log.Printf("[backend] Failed to apply retention policies: %v", err)
+			if !r.Paused() {
+				if err := r.applyRetentionPolicies(ctx); err != nil {
+					log.Printf("[backend] Failed to apply retention policies: %v", err)
+				}
+				if time.Since(lastOrphanCleanup) >= orphanCleanupInterval {
+					r.cleanupOrphans(ctx)
+					lastOrphanCleanup = time.Now()
+				}
+			}
+			// Ingestion goes on while retention is paused, so this runs
+			// either way.
+			if r.afterPass != nil {
+				r.afterPass()
 			}
 		}
 	}
 }
 
-func (r *RetentionManager) ApplyRetentionForContainer(ctx context.Context, containerID string, maxPeriod int64, maxLines int) error {
-	if maxPeriod == 0 && maxLines == 0 {
-		return nil
+// ApplyRetentionForContainer enforces the given limits, with maxPeriod in
+// days, and the absolute max age, and returns how many logs were deleted.
+func (r *RetentionManager) ApplyRetentionForContainer(ctx context.Context, containerID string, maxPeriod int64, maxLines int) (int64, error) {
+	if (maxPeriod == 0 && maxLines == 0 && r.absoluteMaxAge <= 0) || r.Paused() {
+		return 0, nil
 	}
 
-	var err error
+	var removed int64
 
 	if maxLines > 0 {
-		_, err = r.enforceLineLimit(ctx, containerID, maxLines)
+		n, err := r.enforceLineLimit(ctx, containerID, maxLines)
+		removed += n
+		if err != nil {
+			return removed, fmt.Errorf("failed to enforce line limit: %w", err)
+		}
+	}
+
+	if cutoff := r.cutoff(maxPeriod); cutoff > 0 {
+		n, err := r.enforceTimeLimit(ctx, containerID, cutoff)
+		removed += n
 		if err != nil {
-			return fmt.Errorf("failed to enforce line limit: %w", err)
+			return removed, fmt.Errorf("failed to enforce time limit: %w", err)
 		}
 	}
 
+	return removed, nil
+}
+
+// cutoff is the timestamp before which logs are expired under maxPeriod (in
+// days) and the absolute max age, whichever is stricter, or zero when neither is set.
+func (r *RetentionManager) cutoff(maxPeriod int64) int64 {
+	var cutoff int64
 	if maxPeriod > 0 {
-		cutoff := time.Now().Unix() - maxPeriod
-		_, err = r.enforceTimeLimit(ctx, containerID, cutoff)
+		cutoff = time.Now().Add(-time.Duration(maxPeriod) * 24 * time.Hour).UnixNano()
+	}
+	if r.absoluteMaxAge > 0 {
+		cutoff = max(cutoff, time.Now().Add(-r.absoluteMaxAge).UnixNano())
+	}
+	return cutoff
+}
+
+// PreviewRetention reports what ApplyRetentionForContainer would delete with
+// the given limits without deleting anything. It ignores Pause.
+func (r *RetentionManager) PreviewRetention(ctx context.Context, trackedContainerID string, maxPeriod int64, maxLines int) (models.RetentionPreview, error) {
+	var preview models.RetentionPreview
+
+	total, err := r.countLogs(ctx, trackedContainerID)
+	if err != nil {
+		return preview, err
+	}
+
+	// Both limits delete the oldest prunable logs first, so together they
+	// delete whichever prefix of that order is longer.
+	var removed int64
+	if maxLines > 0 && total > maxLines {
+		removed = int64(total - maxLines)
+	}
+	if cutoff := r.cutoff(maxPeriod); cutoff > 0 {
+		var expired int64
+		err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM logs WHERE tracked_container_id = ? AND `+prunable+` AND timestamp < ?`,
+			trackedContainerID, cutoff).Scan(&expired)
 		if err != nil {
-			return fmt.Errorf("failed to enforce time limit: %w", err)
+			return preview, fmt.Errorf("failed to count expired logs: %w", err)
 		}
+		removed = max(removed, expired)
 	}
+	// Pinned and annotated logs stay whatever the limits say.
+	var prunableCount int64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM logs WHERE tracked_container_id = ? AND `+prunable, trackedContainerID).Scan(&prunableCount)
+	if err != nil {
+		return preview, fmt.Errorf("failed to count prunable logs: %w", err)
+	}
+	removed = min(removed, prunableCount)
 
-	return nil
+	var kept, oldestPrunable sql.NullInt64
+	err = r.db.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM logs WHERE tracked_container_id = ? AND NOT (`+prunable+`)`, trackedContainerID).Scan(&kept)
+	if err != nil {
+		return preview, fmt.Errorf("failed to find oldest kept log: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `SELECT timestamp FROM logs WHERE tracked_container_id = ? AND `+prunable+` ORDER BY timestamp ASC, logs.rowid ASC LIMIT 1 OFFSET ?`,
+		trackedContainerID, removed).Scan(&oldestPrunable)
+	if err != nil && err != sql.ErrNoRows {
+		return preview, fmt.Errorf("failed to find oldest remaining log: %w", err)
+	}
+
+	preview.Removed = removed
+	preview.Remaining = int64(total) - removed
+	switch {
+	case kept.Valid && oldestPrunable.Valid:
+		preview.OldestTimestamp = min(kept.Int64, oldestPrunable.Int64)
+	case kept.Valid:
+		preview.OldestTimestamp = kept.Int64
+	case oldestPrunable.Valid:
+		preview.OldestTimestamp = oldestPrunable.Int64
+	}
+	return preview, nil
 }
 
-func (r *RetentionManager) enforceLineLimit(ctx context.Context, trackedContainerID string, maxLines int) (int64, error) {
+func (r *RetentionManager) countLogs(ctx context.Context, trackedContainerID string) (int, error) {
 	var total int
 	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM logs WHERE tracked_container_id = ?`, trackedContainerID).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count logs: %w", err)
 	}
+	return total, nil
+}
+
+// prunable excludes the logs retention keeps regardless of limits: pinned and
+// annotated ones.
+const prunable = `pinned = 0 AND logs.id NOT IN (SELECT log_id FROM annotations)`
+
+func (r *RetentionManager) enforceLineLimit(ctx context.Context, trackedContainerID string, maxLines int) (int64, error) {
+	total, err := r.countLogs(ctx, trackedContainerID)
+	if err != nil {
+		return 0, err
+	}
 
 	if total <= maxLines {
 		return 0, nil
@@ -89,45 +243,120 @@ func (r *RetentionManager) enforceLineLimit(ctx context.Context, trackedContaine
 
 	toRemove := total - maxLines
 
-	result, err := r.db.ExecContext(ctx,
-		`DELETE FROM logs WHERE tracked_container_id = ? AND id IN (
-			SELECT id FROM logs WHERE tracked_container_id = ? ORDER BY timestamp ASC LIMIT ?
-		)`,
-		trackedContainerID, trackedContainerID, toRemove,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("failed to delete old logs: %w", err)
+	return r.prune(ctx, trackedContainerID, toRemove,
+		`tracked_container_id = ? AND `+prunable, trackedContainerID)
+}
+
+func (r *RetentionManager) enforceTimeLimit(ctx context.Context, trackedContainerID string, cutoff int64) (int64, error) {
+	return r.prune(ctx, trackedContainerID, 0,
+		`tracked_container_id = ? AND `+prunable+` AND timestamp < ?`, trackedContainerID, cutoff)
+}
+
+// prune deletes the oldest limit logs selected by filter, or all of them when
+// limit is zero, archiving them first when an archiver is configured. It
+// works a page at a time: each page is read without holding the write lock
+// and then deleted taking it, so neither memory use nor how long inserts wait
+// grows with the number of logs pruned.
+func (r *RetentionManager) prune(ctx context.Context, trackedContainerID string, limit int, filter string, args ...interface{}) (int64, error) {
+	var affected int64
+	for remaining := limit; limit == 0 || remaining > 0; {
+		pageSize := pruneBatchSize
+		if limit > 0 {
+			pageSize = min(pageSize, remaining)
+		}
+		pageArgs := append(append([]interface{}{}, args...), pageSize)
+		ids, err := r.prunePage(ctx, trackedContainerID, filter+` ORDER BY timestamp ASC, logs.rowid ASC LIMIT ?`, pageArgs...)
+		if err != nil || len(ids) == 0 {
+			return affected, err
+		}
+
+		n, err := r.deleteBatch(ctx, ids)
+		affected += n
+		if err != nil {
+			return affected, err
+		}
+		if len(ids) < pageSize {
+			break
+		}
+		remaining -= len(ids)
 	}
+	return affected, nil
+}
 
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+// prunePage returns the IDs of the logs selected by filter, after archiving
+// them when an archiver is configured.
+func (r *RetentionManager) prunePage(ctx context.Context, trackedContainerID, filter string, args ...interface{}) ([]string, error) {
+	if r.archiver == nil {
+		return r.selectIDs(ctx, `SELECT logs.id FROM logs WHERE `+filter, args...)
 	}
 
-	return affected, nil
+	logs, err := queryLogs(r.db, `SELECT `+logColumns+` FROM `+logSource+` WHERE `+filter, args...)
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	if err := r.archiver.Archive(ctx, trackedContainerID, logs); err != nil {
+		if r.archiveRequired {
+			return nil, fmt.Errorf("failed to archive logs, keeping them: %w", err)
+		}
+		log.Printf("[backend] Failed to archive %d logs for %s, deleting anyway: %v", len(logs), trackedContainerID, err)
+	}
+
+	ids := make([]string, 0, len(logs))
+	for _, l := range logs {
+		ids = append(ids, l.ID)
+	}
+	return ids, nil
 }
 
-func (r *RetentionManager) enforceTimeLimit(ctx context.Context, trackedContainerID string, cutoff int64) (int64, error) {
-	result, err := r.db.ExecContext(ctx,
-		`DELETE FROM logs WHERE tracked_container_id = ? AND timestamp < ?`,
-		trackedContainerID, cutoff,
-	)
+func (r *RetentionManager) selectIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete expired logs: %w", err)
+		return nil, fmt.Errorf("failed to select logs: %w", err)
 	}
+	defer rows.Close()
 
-	affected, err := result.RowsAffected()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan log id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *RetentionManager) deleteBatch(ctx context.Context, batch []string) (int64, error) {
+	ids := make([]interface{}, 0, len(batch))
+	for _, id := range batch {
+		ids = append(ids, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	var result sql.Result
+	err := withBusyRetry(ctx, r.busyRetries, func() error {
+		r.writeMu.Lock()
+		defer r.writeMu.Unlock()
+		var err error
+		result, err = r.db.ExecContext(ctx, `DELETE FROM logs WHERE id IN (`+placeholders+`)`, ids...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %w", err)
+	}
+	n, err := result.RowsAffected()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
-
-	return affected, nil
+	return n, nil
 }
 
 func (r *RetentionManager) applyRetentionPolicies(ctx context.Context) error {
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, max_period, max_lines FROM containers WHERE max_period > 0 OR max_lines > 0`,
-	)
+	query := `SELECT id, max_period, max_lines FROM containers WHERE max_period > 0 OR max_lines > 0`
+	if r.absoluteMaxAge > 0 {
+		query = `SELECT id, max_period, max_lines FROM containers`
+	}
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to query containers: %w", err)
 	}
@@ -143,7 +372,7 @@ func (r *RetentionManager) applyRetentionPolicies(ctx context.Context) error {
 			continue
 		}
 
-		if err := r.ApplyRetentionForContainer(ctx, trackedContainerID, maxPeriod, maxLines); err != nil {
+		if _, err := r.ApplyRetentionForContainer(ctx, trackedContainerID, maxPeriod, maxLines); err != nil {
 			log.Printf("[backend] Failed to apply retention for %s: %v", trackedContainerID, err)
 		}
 	}
@@ -151,13 +380,78 @@ func (r *RetentionManager) applyRetentionPolicies(ctx context.Context) error {
 	return nil
 }
 
-func (r *RetentionManager) CleanupOrphanedLogs(ctx context.Context) error {
-	_, err := r.db.ExecContext(ctx,
-		`DELETE FROM logs WHERE tracked_container_id NOT IN (SELECT id FROM containers)`,
-	)
+// EmergencyPrune deletes the oldest percent of every container's logs to make
+// room when the database disk is full. Unlike other deletes it ignores Pause:
+// nothing can be stored until it frees space.
+func (r *RetentionManager) EmergencyPrune(ctx context.Context, percent int) (int64, error) {
+	if percent <= 0 {
+		return 0, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT tracked_container_id, COUNT(*) FROM logs GROUP BY tracked_container_id`)
 	if err != nil {
-		return fmt.Errorf("failed to cleanup orphaned logs: %w", err)
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	counts := make(map[string]int)
+	for rows.Next() {
+		var trackedContainerID string
+		var count int
+		if err := rows.Scan(&trackedContainerID, &count); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan log count: %w", err)
+		}
+		counts[trackedContainerID] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
 	}
 
-	return nil
+	var affected int64
+	for trackedContainerID, count := range counts {
+		toRemove := max(count*percent/100, 1)
+		n, err := r.prune(ctx, trackedContainerID, toRemove,
+			`tracked_container_id = ? AND `+prunable, trackedContainerID)
+		affected += n
+		if err != nil {
+			return affected, fmt.Errorf("failed to prune %s: %w", trackedContainerID, err)
+		}
+	}
+
+	return affected, nil
+}
+
+// CleanupOrphanedLogs deletes logs whose container is no longer tracked, e.g.
+// when it was removed while a collector was still inserting its lines.
+func (r *RetentionManager) CleanupOrphanedLogs(ctx context.Context) (int64, error) {
+	var affected int64
+	for {
+		ids, err := r.selectIDs(ctx, `SELECT id FROM logs WHERE tracked_container_id NOT IN (SELECT id FROM containers) LIMIT ?`, pruneBatchSize)
+		if err != nil {
+			return affected, fmt.Errorf("failed to select orphaned logs: %w", err)
+		}
+		if len(ids) == 0 {
+			return affected, nil
+		}
+
+		n, err := r.deleteBatch(ctx, ids)
+		affected += n
+		if err != nil {
+			return affected, fmt.Errorf("failed to delete orphaned logs: %w", err)
+		}
+		if len(ids) < pruneBatchSize {
+			return affected, nil
+		}
+	}
+}
+
+func (r *RetentionManager) cleanupOrphans(ctx context.Context) {
+	n, err := r.CleanupOrphanedLogs(ctx)
+	if err != nil {
+		log.Printf("[backend] Failed to clean up orphaned logs: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[backend] Removed %d orphaned logs", n)
+	}
 }
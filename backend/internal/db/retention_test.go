@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestPreviewRetentionKeepsPinnedLogs(t *testing.T) {
+	s := newTestDB(t, Options{})
+	c := addTestContainer(t, s, "docker")
+	for i := 0; i < 10; i++ {
+		id := addTestLog(t, s, c, int64(i+1), fmt.Sprintf("line %d", i))
+		if i < 4 {
+			if _, err := s.SetLogPinned(c.ID, id, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	preview, err := s.RetentionManager().PreviewRetention(context.Background(), c.ID, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Removed != 6 || preview.Remaining != 4 {
+		t.Fatalf("preview removes %d and keeps %d, want 6 and the 4 pinned", preview.Removed, preview.Remaining)
+	}
+
+	removed, err := s.RetentionManager().ApplyRetentionForContainer(context.Background(), c.ID, 0, 2)
+	if err != nil || removed != preview.Removed {
+		t.Fatalf("retention removed %d, %v, want %d as previewed", removed, err, preview.Removed)
+	}
+}
+
+type pageArchiver struct {
+	mu    sync.Mutex
+	pages []int
+}
+
+func (a *pageArchiver) Archive(ctx context.Context, trackedContainerID string, logs []models.LogEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pages = append(a.pages, len(logs))
+	return nil
+}
+
+func TestPruneInPagesWhileInserting(t *testing.T) {
+	s := newTestDB(t, Options{BusyTimeout: 5 * time.Second})
+	c := addTestContainer(t, s, "docker")
+	archiver := &pageArchiver{}
+	s.RetentionManager().SetArchiver(archiver, true)
+
+	old := 2*pruneBatchSize + 50
+	for i := 0; i < old; i++ {
+		addTestLog(t, s, c, int64(i+1), fmt.Sprintf("old %d", i))
+	}
+
+	const inserted = 200
+	errs := make(chan error, 1)
+	go func() {
+		for i := 0; i < inserted; i++ {
+			entry := &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: time.Now().UnixNano(), Message: fmt.Sprintf("new %d", i)}
+			if err := s.AddLog(context.Background(), entry); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	removed, err := s.RetentionManager().ApplyRetentionForContainer(context.Background(), c.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("insert during prune: %v", err)
+	}
+
+	if removed != int64(old) {
+		t.Fatalf("removed %d, want %d", removed, old)
+	}
+	if total, _ := s.RetentionManager().countLogs(context.Background(), c.ID); total != inserted {
+		t.Fatalf("%d logs left, want the %d new ones", total, inserted)
+	}
+	for _, page := range archiver.pages {
+		if page > pruneBatchSize {
+			t.Fatalf("archived pages %v, want none over %d", archiver.pages, pruneBatchSize)
+		}
+	}
+}
+
+func TestAbsoluteMaxAge(t *testing.T) {
+	s := newTestDB(t, Options{})
+	r := s.RetentionManager()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour).UnixNano()
+
+	unlimited := addTestContainer(t, s, "unlimited")
+	pinned := addTestLog(t, s, unlimited, old, "pinned")
+	if _, err := s.SetLogPinned(unlimited.ID, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+	addTestLog(t, s, unlimited, old+1, "expired")
+	addTestLog(t, s, unlimited, now.UnixNano(), "recent")
+
+	limited, err := s.AddContainer(&models.AddContainerRequest{Name: "limited", MaxLines: 2}, "limited", "limited", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestLog(t, s, limited, old, "expired")
+	for i := 0; i < 3; i++ {
+		addTestLog(t, s, limited, now.UnixNano()+int64(i), fmt.Sprintf("recent %d", i))
+	}
+
+	// Without an absolute max age, unlimited containers are left alone.
+	if err := r.applyRetentionPolicies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, unlimited); len(got) != 3 {
+		t.Fatalf("unlimited container kept %v, want all 3 logs", got)
+	}
+
+	r.SetAbsoluteMaxAge(24 * time.Hour)
+	if err := r.applyRetentionPolicies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, unlimited); fmt.Sprint(got) != "[pinned recent]" {
+		t.Fatalf("unlimited container kept %v, want the pinned and the recent log", got)
+	}
+	// maxLines still applies alongside the absolute max age.
+	if got := testMessages(t, s, limited); fmt.Sprint(got) != "[recent 1 recent 2]" {
+		t.Fatalf("limited container kept %v, want its 2 newest logs", got)
+	}
+}
+
+func TestMaxPeriodIsInDays(t *testing.T) {
+	s := newTestDB(t, Options{})
+	r := s.RetentionManager()
+	c := addTestContainer(t, s, "docker")
+	now := time.Now()
+	addTestLog(t, s, c, now.Add(-72*time.Hour).UnixNano(), "three days old")
+	addTestLog(t, s, c, now.Add(-36*time.Hour).UnixNano(), "a day and a half old")
+	addTestLog(t, s, c, now.Add(-time.Hour).UnixNano(), "an hour old")
+
+	if _, err := r.ApplyRetentionForContainer(context.Background(), c.ID, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, c); fmt.Sprint(got) != "[a day and a half old an hour old]" {
+		t.Fatalf("maxPeriod 2 kept %v, want the logs from the last 2 days", got)
+	}
+
+	// The stricter of maxPeriod and the absolute max age applies.
+	r.SetAbsoluteMaxAge(240 * time.Hour)
+	if _, err := r.ApplyRetentionForContainer(context.Background(), c.ID, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, c); fmt.Sprint(got) != "[an hour old]" {
+		t.Fatalf("maxPeriod 1 under a 10 day absolute max age kept %v, want the last day", got)
+	}
+	r.SetAbsoluteMaxAge(30 * time.Minute)
+	if _, err := r.ApplyRetentionForContainer(context.Background(), c.ID, 7, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := testMessages(t, s, c); len(got) != 0 {
+		t.Fatalf("maxPeriod 7 under a 30 minute absolute max age kept %v, want nothing", got)
+	}
+}
+
+func TestContainerSizesRefreshAfterRetentionPass(t *testing.T) {
+	s := newTestDB(t, Options{})
+	c := addTestContainer(t, s, "docker")
+	addTestLog(t, s, c, 1, "12345")
+
+	sizes, err := s.GetContainerSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[c.ID] != 5 {
+		t.Fatalf("size = %d, want 5", sizes[c.ID])
+	}
+
+	// Listing containers reads the cache instead of scanning the logs again.
+	addTestLog(t, s, c, 2, "67890")
+	if sizes, _ := s.GetContainerSizes(); sizes[c.ID] != 5 {
+		t.Fatalf("size between passes = %d, want the cached 5", sizes[c.ID])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.RetentionManager().Start(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if sizes, _ := s.GetContainerSizes(); sizes[c.ID] == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("container sizes were not refreshed by the retention pass")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
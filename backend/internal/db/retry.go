@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const busyRetryBase = 20 * time.Millisecond
+
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// withBusyRetry runs fn again up to retries times while it fails with
+// SQLITE_BUSY or SQLITE_LOCKED, which the busy timeout does not always absorb
+// (e.g. a read transaction that needs to upgrade to a write in WAL mode).
+// Waits double each attempt with jitter so competing writers spread out.
+func withBusyRetry(ctx context.Context, retries int, fn func() error) error {
+	err := fn()
+	for attempt := 0; attempt < retries && isBusy(err); attempt++ {
+		delay := busyRetryBase << attempt
+		delay += rand.N(delay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		err = fn()
+	}
+	return err
+}
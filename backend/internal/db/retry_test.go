@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestBusyInsertDoesNotHoldLockWhileWaiting(t *testing.T) {
+	s := newTestDB(t, Options{BusyTimeout: time.Millisecond, BusyRetries: 8})
+	c := addTestContainer(t, s, "docker")
+
+	// Another connection holds the write lock, so the insert keeps failing
+	// with SQLITE_BUSY and backs off.
+	ctx := context.Background()
+	blocker, err := s.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocker.Close()
+	if _, err := blocker.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.AddLog(ctx, &models.LogEntry{TrackedContainerID: c.ID, ContainerID: c.ContainerID, Timestamp: 1, Message: "waiting"})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := s.GetAllContainers(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("listing containers took %s while an insert was retrying", elapsed)
+	}
+
+	if _, err := blocker.ExecContext(ctx, `COMMIT`); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("insert after the lock was released: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package db
+
+import "testing"
+
+func TestFailedLogsSwapRollsBackContainer(t *testing.T) {
+	s := newTestDB(t, Options{})
+	c := addTestContainer(t, s, "old")
+	addTestLog(t, s, c, 1, "before the swap")
+
+	if _, err := s.db.Exec(`CREATE TRIGGER fail_logs_swap BEFORE UPDATE OF container_id ON logs
+		BEGIN SELECT RAISE(ABORT, 'logs update failed'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SwapContainer("old", "new", "renamed"); err == nil {
+		t.Fatal("swap succeeded although its logs update failed")
+	}
+
+	swapped, err := s.GetContainerByID(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped.ContainerID != "old" || swapped.ContainerName != "old" {
+		t.Fatalf("container is %s (%s) after the failed swap, want old (old)", swapped.ContainerID, swapped.ContainerName)
+	}
+}
@@ -3,11 +3,16 @@ package docker
 import (
 	"bufio"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,13 +27,62 @@ type DockerClient struct {
 }
 
 type LogMessage struct {
-	Container string    `json:"container"`
-	Log       string    `json:"log"`
-	Timestamp time.Time `json:"timestamp"`
+	Container string            `json:"container"`
+	Log       string            `json:"log"`
+	Timestamp time.Time         `json:"timestamp"`
+	Stream    string            `json:"stream,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+
+	// Err is set on the last message of a stream that broke off rather than
+	// ended, e.g. when the connection to the daemon dropped.
+	Err error `json:"-"`
 }
 
-func NewDockerClient() (*DockerClient, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+type StreamOptions struct {
+	Since time.Time
+	Tail  int
+
+	// Details asks Docker to prefix each line with the attributes set via
+	// --log-opt labels/env, which are returned in LogMessage.Details.
+	Details bool
+
+	// NoFollow returns the logs written so far and closes the channel
+	// instead of following new output.
+	NoFollow bool
+
+	NoStdout bool
+	NoStderr bool
+}
+
+func NewDockerClient(contextName string) (*DockerClient, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+
+	if os.Getenv("DOCKER_HOST") == "" {
+		// A context that cannot be used as configured is an error rather than
+		// a silent fallback, e.g. to an unencrypted connection.
+		endpoint, err := resolveContext(contextName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve docker context: %w", err)
+		}
+		if endpoint.Host != "" {
+			opts = append(opts, client.WithHost(endpoint.Host))
+		}
+		if endpoint.usesTLS() {
+			opts = append(opts, client.WithTLSClientConfig(endpoint.CAFile, endpoint.CertFile, endpoint.KeyFile))
+		}
+	}
+
+	return newDockerClient(opts)
+}
+
+// NewDockerClientForHost connects to the daemon at host, e.g.
+// unix:///run/user/1000/docker.sock, ignoring DOCKER_HOST and contexts.
+func NewDockerClientForHost(host string) (*DockerClient, error) {
+	return newDockerClient([]client.Opt{client.WithHost(host), client.WithAPIVersionNegotiation()})
+}
+
+func newDockerClient(opts []client.Opt) (*DockerClient, error) {
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
@@ -81,6 +135,14 @@ func (d *DockerClient) FindContainerByName(ctx context.Context, name string) (*t
 
 	exactMatch := strings.TrimPrefix(name, "/")
 
+	for _, c := range containers {
+		for _, n := range c.Names {
+			if strings.EqualFold(strings.TrimPrefix(n, "/"), exactMatch) {
+				return &c, nil
+			}
+		}
+	}
+
 	for _, c := range containers {
 		for _, n := range c.Names {
 			cleanName := strings.TrimPrefix(n, "/")
@@ -97,65 +159,251 @@ func (d *DockerClient) FindContainerByName(ctx context.Context, name string) (*t
 	return nil, nil
 }
 
-func (d *DockerClient) StreamContainerLogs(ctx context.Context, containerID string, since time.Time) (<-chan LogMessage, error) {
+func (d *DockerClient) StreamContainerLogs(ctx context.Context, containerID string, streamOpts StreamOptions) (<-chan LogMessage, error) {
 	if d.cli == nil {
 		return nil, fmt.Errorf("docker client not initialized")
 	}
 
+	opts := container.LogsOptions{
+		ShowStdout: !streamOpts.NoStdout,
+		ShowStderr: !streamOpts.NoStderr,
+		Follow:     !streamOpts.NoFollow,
+		Tail:       "",
+		Timestamps: true,
+		Details:    streamOpts.Details,
+	}
+
+	if !streamOpts.Since.IsZero() {
+		opts.Since = formatSince(streamOpts.Since)
+	}
+	if streamOpts.Tail > 0 {
+		opts.Tail = strconv.Itoa(streamOpts.Tail)
+	}
+
+	reader, err := d.cli.ContainerLogs(ctx, containerID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log stream: %w", err)
+	}
+
 	logsChan := make(chan LogMessage)
 
 	go func() {
 		defer close(logsChan)
+		defer reader.Close()
 
-		opts := container.LogsOptions{
-			ShowStdout: true,
-			ShowStderr: true,
-			Follow:     true,
-			Tail:       "",
-			Timestamps: true,
+		if err := readLogLines(ctx, reader, containerID, streamOpts.Details, logsChan); err != nil && ctx.Err() == nil {
+			select {
+			case logsChan <- LogMessage{Container: containerID, Err: err}:
+			case <-ctx.Done():
+			}
 		}
+	}()
+
+	return logsChan, nil
+}
 
-		if !since.IsZero() {
-			opts.Since = since.Format(time.RFC3339)
+// OldestLogTimestamp returns the timestamp of the oldest line Docker can still
+// return for the container, or the zero time when it has none.
+func (d *DockerClient) OldestLogTimestamp(ctx context.Context, containerID string) (time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logsChan, err := d.StreamContainerLogs(ctx, containerID, StreamOptions{NoFollow: true})
+	if err != nil {
+		return time.Time{}, err
+	}
+	msg, ok := <-logsChan
+	if !ok {
+		return time.Time{}, nil
+	}
+	if msg.Err != nil {
+		return time.Time{}, msg.Err
+	}
+	return msg.Timestamp, nil
+}
+
+// Multiplexed log streams (containers without a TTY) are a sequence of
+// frames, each an 8-byte header (stream type, three zero bytes, big-endian
+// payload size) followed by exactly that many payload bytes.
+const (
+	frameHeaderSize = 8
+	maxFrameSize    = 16 << 20
+)
+
+var frameStreams = map[byte]string{1: "stdout", 2: "stderr"}
+
+// readLogLines delivers the lines of a log stream until it ends, returning
+// the error that broke it off, if any.
+func readLogLines(ctx context.Context, reader io.Reader, containerID string, details bool, logsChan chan<- LogMessage) error {
+	bufReader := bufio.NewReader(reader)
+
+	// With timestamps on, TTY output starts with a digit, so a frame header
+	// is unambiguous.
+	if header, err := bufReader.Peek(frameHeaderSize); err == nil && isFrameHeader(header) {
+		return readLogFrames(ctx, bufReader, containerID, details, logsChan)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			line, err := bufReader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				log.Printf("[backend] Log stream error for %s: %v", containerID, err)
+				return err
+			}
+
+			// A container that exits without a trailing newline leaves its
+			// last line in the buffer at EOF; emit it rather than drop it.
+			if len(line) > 0 {
+				timestamp, cleanLog := parseDockerTimestamp(string(line))
+				msg := LogMessage{Container: containerID, Log: cleanLog, Timestamp: timestamp}
+				if !sendLog(ctx, logsChan, msg, details) {
+					return nil
+				}
+			}
+
+			if err == io.EOF {
+				return nil
+			}
 		}
+	}
+}
 
-		reader, err := d.cli.ContainerLogs(ctx, containerID, opts)
-		if err != nil {
-			log.Printf("[backend] ContainerLogs error for %s: %v", containerID, err)
-			return
+func readLogFrames(ctx context.Context, reader io.Reader, containerID string, details bool, logsChan chan<- LogMessage) error {
+	header := make([]byte, frameHeaderSize)
+	var payload []byte
+	for ctx.Err() == nil {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			log.Printf("[backend] Log stream error for %s: %v", containerID, err)
+			return err
+		}
+		if !isFrameHeader(header) {
+			log.Printf("[backend] Log stream for %s is corrupt: unexpected frame header %x", containerID, header)
+			return fmt.Errorf("unexpected frame header %x", header)
 		}
-		defer reader.Close()
 
-		bufReader := bufio.NewReader(reader)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				line, err := bufReader.ReadBytes('\n')
-				if err == io.EOF {
-					return
-				}
-				if err != nil {
-					log.Printf("[backend] Log stream error for %s: %v", containerID, err)
-					return
-				}
+		size := binary.BigEndian.Uint32(header[4:])
+		if size > maxFrameSize {
+			log.Printf("[backend] Log stream for %s is corrupt: %d byte frame", containerID, size)
+			return fmt.Errorf("%d byte frame", size)
+		}
+		payload = slices.Grow(payload[:0], int(size))[:size]
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			log.Printf("[backend] Log stream error for %s: %v", containerID, err)
+			return err
+		}
 
-				lineStr := string(line)
-				timestamp, cleanLog := parseDockerTimestamp(lineStr)
+		// Type 3 frames carry an error from the daemon instead of output.
+		if header[0] == 3 {
+			log.Printf("[backend] Log stream error for %s: %s", containerID, strings.TrimSpace(string(payload)))
+			return fmt.Errorf("daemon error: %s", strings.TrimSpace(string(payload)))
+		}
 
-				if cleanLog != "" {
-					logsChan <- LogMessage{
-						Container: containerID,
-						Log:       cleanLog,
-						Timestamp: timestamp,
-					}
-				}
+		for _, msg := range splitFrame(string(payload)) {
+			msg.Container = containerID
+			msg.Stream = frameStreams[header[0]]
+			if !sendLog(ctx, logsChan, msg, details) {
+				return nil
 			}
 		}
-	}()
+	}
+	return nil
+}
 
-	return logsChan, nil
+func isFrameHeader(header []byte) bool {
+	return header[0] <= 3 && header[1] == 0 && header[2] == 0 && header[3] == 0
+}
+
+// splitFrame turns a frame payload into messages. Every message starts with
+// a timestamp, so lines without one are newlines embedded in the previous
+// message and stay part of it.
+func splitFrame(payload string) []LogMessage {
+	var msgs []LogMessage
+	for _, line := range strings.Split(strings.TrimSuffix(payload, "\n"), "\n") {
+		timestamp, message, ok := cutTimestamp(line)
+		if !ok && len(msgs) > 0 {
+			msgs[len(msgs)-1].Log += "\n" + line
+			continue
+		}
+		if !ok {
+			timestamp = time.Now()
+		}
+		msgs = append(msgs, LogMessage{Log: message, Timestamp: timestamp})
+	}
+	return msgs
+}
+
+func cutTimestamp(line string) (time.Time, string, bool) {
+	tsStr, message, _ := strings.Cut(line, " ")
+	timestamp, err := time.Parse(time.RFC3339Nano, tsStr)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return timestamp, message, true
+}
+
+// sendLog splits off details when requested and delivers non-empty messages.
+// msg.Log is the untrimmed text after the timestamp, since its leading space
+// is what marks a line without details. It returns false once ctx is
+// cancelled.
+func sendLog(ctx context.Context, logsChan chan<- LogMessage, msg LogMessage, details bool) bool {
+	if details {
+		msg.Details, msg.Log = splitDetails(msg.Log)
+	}
+	msg.Log = strings.TrimSpace(msg.Log)
+	if msg.Log == "" {
+		return true
+	}
+	select {
+	case logsChan <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// splitDetails separates the "key=value,key2=value2 " prefix Docker adds when
+// details are requested. Lines without attributes get an empty prefix, so they
+// start with the separating space and the message is left whole even if its
+// first word looks like key=value. Without that space, the first word only
+// counts as details if every comma-separated part is key=value.
+func splitDetails(line string) (map[string]string, string) {
+	if strings.HasPrefix(line, " ") {
+		return nil, line
+	}
+	prefix, rest, found := strings.Cut(line, " ")
+	if !found || !strings.Contains(prefix, "=") {
+		return nil, line
+	}
+
+	attrs := make(map[string]string)
+	for _, pair := range strings.Split(prefix, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, line
+		}
+		key, errKey := url.QueryUnescape(key)
+		value, errValue := url.QueryUnescape(value)
+		if errKey != nil || errValue != nil {
+			return nil, line
+		}
+		attrs[key] = value
+	}
+
+	return attrs, rest
+}
+
+// formatSince renders since as the "seconds.nanoseconds" Unix form Docker
+// accepts, keeping nanosecond precision; RFC3339 is truncated to seconds.
+// Docker includes entries at exactly since, and the boundary line is
+// deduplicated by the logs unique constraint.
+func formatSince(since time.Time) string {
+	return fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())
 }
 
 func cleanLogLine(line string) string {
@@ -179,10 +427,6 @@ func parseDockerTimestamp(line string) (time.Time, string) {
 		return time.Now(), line
 	}
 
-	if len(line) >= 8 && (line[0] == 1 || line[0] == 2) {
-		line = line[8:]
-	}
-
 	for i := 0; i < len(line) && i < 100; i++ {
 		b := line[i]
 		if (b >= '0' && b <= '9') || b == '-' {
@@ -196,8 +440,7 @@ func parseDockerTimestamp(line string) (time.Time, string) {
 		tsStr := line[:idx]
 		ts, err := time.Parse(time.RFC3339Nano, tsStr)
 		if err == nil {
-			message := strings.TrimSpace(line[idx+1:])
-			return ts, message
+			return ts, line[idx+1:]
 		}
 	}
 
@@ -217,6 +460,11 @@ func (d *DockerClient) InspectContainer(ctx context.Context, containerID string)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
+	// A partial response leaves the embedded base nil, which would make
+	// every field access through it panic; State and HostConfig stay nil.
+	if resp.ContainerJSONBase == nil {
+		resp.ContainerJSONBase = &types.ContainerJSONBase{}
+	}
 
 	return &resp, nil
 }
@@ -251,6 +499,27 @@ type ContainerInfo struct {
 	State   string    `json:"state"`
 }
 
+type ResolveResult struct {
+	Container  ContainerInfo   `json:"container"`
+	Candidates []ContainerInfo `json:"candidates"`
+}
+
+func toContainerInfo(c types.Container) ContainerInfo {
+	name := ""
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	return ContainerInfo{
+		ID:      c.ID,
+		Name:    name,
+		Image:   c.Image,
+		Status:  c.Status,
+		Created: time.Unix(c.Created, 0),
+		State:   c.State,
+	}
+}
+
 func (d *DockerClient) ListContainersInfo(ctx context.Context) ([]ContainerInfo, error) {
 	containers, err := d.ListContainers(ctx)
 	if err != nil {
@@ -259,22 +528,140 @@ func (d *DockerClient) ListContainersInfo(ctx context.Context) ([]ContainerInfo,
 
 	var info []ContainerInfo
 	for _, c := range containers {
-		name := ""
-		if len(c.Names) > 0 {
-			name = strings.TrimPrefix(c.Names[0], "/")
+		info = append(info, toContainerInfo(c))
+	}
+
+	return info, nil
+}
+
+// FindContainerByImage returns the single running container created from
+// image. When several match, it returns no container and the matches as
+// candidates instead.
+func (d *DockerClient) FindContainerByImage(ctx context.Context, image string) (*types.Container, []ContainerInfo, error) {
+	containers, err := d.ListContainers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var matches []types.Container
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		if c.Image == image || c.Image == image+":latest" || c.ImageID == image || c.ImageID == "sha256:"+image {
+			matches = append(matches, c)
 		}
+	}
 
-		info = append(info, ContainerInfo{
-			ID:      c.ID,
-			Name:    name,
-			Image:   c.Image,
-			Status:  c.Status,
-			Created: time.Unix(c.Created, 0),
-			State:   c.State,
-		})
+	switch len(matches) {
+	case 0:
+		return nil, nil, nil
+	case 1:
+		return &matches[0], nil, nil
 	}
 
-	return info, nil
+	candidates := make([]ContainerInfo, 0, len(matches))
+	for _, c := range matches {
+		candidates = append(candidates, toContainerInfo(c))
+	}
+	return nil, candidates, nil
+}
+
+func (d *DockerClient) ResolveContainer(ctx context.Context, name string) (*ResolveResult, error) {
+	match, err := d.FindContainerByName(ctx, name)
+	if err != nil || match == nil {
+		return nil, err
+	}
+
+	containers, err := d.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ResolveResult{
+		Container:  toContainerInfo(*match),
+		Candidates: []ContainerInfo{},
+	}
+
+	prefix := strings.TrimPrefix(name, "/")
+	for _, c := range containers {
+		if c.ID == match.ID {
+			continue
+		}
+		if strings.HasPrefix(c.ID, prefix) {
+			result.Candidates = append(result.Candidates, toContainerInfo(c))
+			continue
+		}
+		for _, n := range c.Names {
+			if strings.HasPrefix(strings.TrimPrefix(n, "/"), prefix) {
+				result.Candidates = append(result.Candidates, toContainerInfo(c))
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// SuggestContainers returns up to limit containers whose names look like name:
+// those containing it case-insensitively, then those a few typos away.
+func (d *DockerClient) SuggestContainers(ctx context.Context, name string, limit int) ([]ContainerInfo, error) {
+	containers, err := d.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type suggestion struct {
+		info     ContainerInfo
+		distance int
+	}
+
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	maxDistance := max(2, len(name)/3)
+
+	var suggestions []suggestion
+	for _, c := range containers {
+		info := toContainerInfo(c)
+		candidate := strings.ToLower(info.Name)
+		if strings.Contains(candidate, name) || strings.Contains(name, candidate) {
+			suggestions = append(suggestions, suggestion{info, 0})
+			continue
+		}
+		if distance := editDistance(candidate, name); distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{info, distance})
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return a.distance - b.distance
+	})
+
+	result := make([]ContainerInfo, 0, min(len(suggestions), limit))
+	for _, s := range suggestions[:min(len(suggestions), limit)] {
+		result = append(result, s.info)
+	}
+	return result, nil
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
 }
 
 func (d *DockerClient) HTTPClient() *http.Client {
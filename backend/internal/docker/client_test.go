@@ -0,0 +1,158 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspectContainerPartialResponse(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer daemon.Close()
+
+	dc, err := NewDockerClientForHost("tcp://" + strings.TrimPrefix(daemon.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+
+	info, err := dc.InspectContainer(context.Background(), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContainerJSONBase == nil {
+		t.Fatal("ContainerJSONBase is nil")
+	}
+	if info.State != nil || info.HostConfig != nil {
+		t.Fatalf("state = %v, host config = %v, want both nil", info.State, info.HostConfig)
+	}
+}
+
+func TestStreamSinceKeepsNanoseconds(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/logs") {
+			w.Write([]byte("{}"))
+			return
+		}
+		// Like the daemon, accept "seconds.nanoseconds" and include the line
+		// at exactly since.
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			sec, nsec, _ := strings.Cut(s, ".")
+			secs, err1 := strconv.ParseInt(sec, 10, 64)
+			nsecs, err2 := strconv.ParseInt(nsec, 10, 64)
+			if err1 != nil || err2 != nil {
+				http.Error(w, "bad since "+s, http.StatusBadRequest)
+				return
+			}
+			since = time.Unix(secs, nsecs)
+		}
+		for i := 1; i <= 5; i++ {
+			ts := base.Add(time.Duration(i) * 100)
+			if !ts.Before(since) {
+				fmt.Fprintf(w, "%s line %d\n", ts.Format(time.RFC3339Nano), i)
+			}
+		}
+	}))
+	defer daemon.Close()
+
+	dc, err := NewDockerClientForHost("tcp://" + strings.TrimPrefix(daemon.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+
+	logs, err := dc.StreamContainerLogs(context.Background(), "abc", StreamOptions{Since: base.Add(300), NoFollow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for msg := range logs {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		got = append(got, msg.Log)
+	}
+	if fmt.Sprint(got) != "[line 3 line 4 line 5]" {
+		t.Fatalf("streamed %q, want the lines from since on", got)
+	}
+}
+
+func TestFinalLineWithoutNewline(t *testing.T) {
+	ts := "2026-01-02T03:04:05.000000001Z"
+	raw := ts + " starting\n" + ts + " exiting with code 1"
+
+	frame := func(payload string) []byte {
+		header := make([]byte, frameHeaderSize)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		return append(header, payload...)
+	}
+	framed := append(frame(ts+" starting\n"), frame(ts+" exiting with code 1")...)
+
+	for name, stream := range map[string][]byte{"tty": []byte(raw), "multiplexed": framed} {
+		t.Run(name, func(t *testing.T) {
+			logs := make(chan LogMessage, 10)
+			if err := readLogLines(context.Background(), bytes.NewReader(stream), "abc", false, logs); err != nil {
+				t.Fatal(err)
+			}
+			close(logs)
+			var got []string
+			for msg := range logs {
+				got = append(got, msg.Log)
+			}
+			if fmt.Sprint(got) != "[starting exiting with code 1]" {
+				t.Fatalf("read %q, want both lines", got)
+			}
+		})
+	}
+}
+
+func TestDetailsPrefix(t *testing.T) {
+	ts := "2026-01-02T03:04:05.000000001Z"
+	frame := func(payload string) []byte {
+		header := make([]byte, frameHeaderSize)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		return append(header, payload...)
+	}
+
+	tests := []struct {
+		name    string
+		line    string
+		details map[string]string
+		message string
+	}{
+		{"attributes", ts + " com.example.team=api,env=prod level=info msg=started", map[string]string{"com.example.team": "api", "env": "prod"}, "level=info msg=started"},
+		// Without attributes Docker still writes the separating space, so a
+		// message that looks like key=value stays whole.
+		{"no attributes", ts + "  level=info msg=started", nil, "level=info msg=started"},
+		{"no attributes, plain message", ts + "  starting", nil, "starting"},
+	}
+	for _, tt := range tests {
+		for name, stream := range map[string][]byte{"tty": []byte(tt.line + "\n"), "multiplexed": frame(tt.line + "\n")} {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				logs := make(chan LogMessage, 10)
+				if err := readLogLines(context.Background(), bytes.NewReader(stream), "abc", true, logs); err != nil {
+					t.Fatal(err)
+				}
+				close(logs)
+				msg := <-logs
+				if msg.Log != tt.message || fmt.Sprint(msg.Details) != fmt.Sprint(tt.details) {
+					t.Fatalf("read details %v, message %q; want %v, %q", msg.Details, msg.Log, tt.details, tt.message)
+				}
+			})
+		}
+	}
+}
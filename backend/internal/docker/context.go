@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type contextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints struct {
+		Docker struct {
+			Host          string `json:"Host"`
+			SkipTLSVerify bool   `json:"SkipTLSVerify"`
+		} `json:"docker"`
+	} `json:"Endpoints"`
+}
+
+// contextEndpoint is the daemon a Docker context points at. The TLS files are
+// set when the context was created with TLS material and are empty otherwise.
+type contextEndpoint struct {
+	Host     string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+func (e contextEndpoint) usesTLS() bool {
+	return e.CAFile != "" || e.CertFile != "" || e.KeyFile != ""
+}
+
+func dockerConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+func currentContextName(configDir string) (string, error) {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read docker config: %w", err)
+	}
+
+	var config struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse docker config: %w", err)
+	}
+	return config.CurrentContext, nil
+}
+
+// resolveContext returns the daemon endpoint of the named Docker context,
+// falling back to DOCKER_CONTEXT and then the CLI's currentContext. An empty
+// host means the default context is active.
+func resolveContext(name string) (contextEndpoint, error) {
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return contextEndpoint{}, fmt.Errorf("failed to locate docker config: %w", err)
+	}
+
+	if name == "" {
+		name, err = currentContextName(configDir)
+		if err != nil {
+			return contextEndpoint{}, err
+		}
+	}
+
+	if name == "" || name == "default" {
+		return contextEndpoint{}, nil
+	}
+
+	digest := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(digest[:])
+	metaPath := filepath.Join(configDir, "contexts", "meta", id, "meta.json")
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return contextEndpoint{}, fmt.Errorf("failed to read docker context %q: %w", name, err)
+	}
+
+	var meta contextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return contextEndpoint{}, fmt.Errorf("failed to parse docker context %q: %w", name, err)
+	}
+
+	if meta.Endpoints.Docker.Host == "" {
+		return contextEndpoint{}, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+	if meta.Endpoints.Docker.SkipTLSVerify {
+		return contextEndpoint{}, fmt.Errorf("docker context %q skips TLS verification, which is not supported", name)
+	}
+
+	// The CLI keeps a context's certificates next to its metadata, under
+	// tls/<digest>/docker.
+	endpoint := contextEndpoint{Host: meta.Endpoints.Docker.Host}
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	for file, path := range map[string]*string{"ca.pem": &endpoint.CAFile, "cert.pem": &endpoint.CertFile, "key.pem": &endpoint.KeyFile} {
+		if _, err := os.Stat(filepath.Join(tlsDir, file)); err == nil {
+			*path = filepath.Join(tlsDir, file)
+		} else if !os.IsNotExist(err) {
+			return contextEndpoint{}, fmt.Errorf("failed to read TLS files of docker context %q: %w", name, err)
+		}
+	}
+	if (endpoint.CertFile == "") != (endpoint.KeyFile == "") {
+		return contextEndpoint{}, fmt.Errorf("docker context %q has a TLS certificate without its key, or a key without its certificate", name)
+	}
+
+	return endpoint, nil
+}
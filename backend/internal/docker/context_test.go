@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeContext stores a context the way the Docker CLI does and returns the
+// directory its TLS files belong in.
+func writeContext(t *testing.T, configDir, name, meta string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(digest[:])
+
+	metaDir := filepath.Join(configDir, "contexts", "meta", id)
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(configDir, "contexts", "tls", id, "docker")
+}
+
+func writeTLSFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("pem"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveContext(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	writeContext(t, configDir, "plain", `{"Name":"plain","Endpoints":{"docker":{"Host":"tcp://plain:2375"}}}`)
+	tlsDir := writeContext(t, configDir, "secure", `{"Name":"secure","Endpoints":{"docker":{"Host":"tcp://secure:2376"}}}`)
+	writeTLSFiles(t, tlsDir, "ca.pem", "cert.pem", "key.pem")
+	caDir := writeContext(t, configDir, "ca-only", `{"Name":"ca-only","Endpoints":{"docker":{"Host":"tcp://ca:2376"}}}`)
+	writeTLSFiles(t, caDir, "ca.pem")
+
+	tests := []struct {
+		name string
+		want contextEndpoint
+	}{
+		{"default", contextEndpoint{}},
+		{"plain", contextEndpoint{Host: "tcp://plain:2375"}},
+		{"secure", contextEndpoint{
+			Host:     "tcp://secure:2376",
+			CAFile:   filepath.Join(tlsDir, "ca.pem"),
+			CertFile: filepath.Join(tlsDir, "cert.pem"),
+			KeyFile:  filepath.Join(tlsDir, "key.pem"),
+		}},
+		{"ca-only", contextEndpoint{Host: "tcp://ca:2376", CAFile: filepath.Join(caDir, "ca.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveContext(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("resolveContext(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCurrentContext(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	writeContext(t, configDir, "from-config", `{"Name":"from-config","Endpoints":{"docker":{"Host":"tcp://config:2375"}}}`)
+	writeContext(t, configDir, "from-env", `{"Name":"from-env","Endpoints":{"docker":{"Host":"tcp://env:2375"}}}`)
+
+	got, err := resolveContext("")
+	if err != nil || got.Host != "" {
+		t.Fatalf("without a config file: %+v, %v; want the default context", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"from-config"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := resolveContext(""); err != nil || got.Host != "tcp://config:2375" {
+		t.Fatalf("currentContext: %+v, %v", got, err)
+	}
+
+	t.Setenv("DOCKER_CONTEXT", "from-env")
+	if got, err := resolveContext(""); err != nil || got.Host != "tcp://env:2375" {
+		t.Fatalf("DOCKER_CONTEXT: %+v, %v", got, err)
+	}
+
+	// An explicit name wins over both.
+	if got, err := resolveContext("from-config"); err != nil || got.Host != "tcp://config:2375" {
+		t.Fatalf("explicit name: %+v, %v", got, err)
+	}
+}
+
+func TestResolveContextErrors(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	writeContext(t, configDir, "insecure", `{"Name":"insecure","Endpoints":{"docker":{"Host":"tcp://insecure:2376","SkipTLSVerify":true}}}`)
+	keyless := writeContext(t, configDir, "keyless", `{"Name":"keyless","Endpoints":{"docker":{"Host":"tcp://keyless:2376"}}}`)
+	writeTLSFiles(t, keyless, "ca.pem", "cert.pem")
+	writeContext(t, configDir, "no-endpoint", `{"Name":"no-endpoint","Endpoints":{}}`)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"missing", "failed to read docker context"},
+		{"insecure", "skips TLS verification"},
+		{"keyless", "without its key"},
+		{"no-endpoint", "has no docker endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveContext(tt.name)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("resolveContext(%q) error = %v, want %q", tt.name, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDockerClientRejectsUnusableContext(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("DOCKER_HOST", "")
+
+	writeContext(t, configDir, "insecure", `{"Name":"insecure","Endpoints":{"docker":{"Host":"tcp://insecure:2376","SkipTLSVerify":true}}}`)
+
+	if dc, err := NewDockerClient("insecure"); err == nil {
+		dc.Close()
+		t.Fatal("expected an error for a context that skips TLS verification")
+	}
+
+	// The context's certificates are loaded, so unreadable ones fail here
+	// instead of the client connecting without TLS.
+	tlsDir := writeContext(t, configDir, "secure", `{"Name":"secure","Endpoints":{"docker":{"Host":"tcp://secure:2376"}}}`)
+	writeTLSFiles(t, tlsDir, "ca.pem")
+	if dc, err := NewDockerClient("secure"); err == nil {
+		dc.Close()
+		t.Fatal("expected the invalid CA of the context to be rejected")
+	}
+}
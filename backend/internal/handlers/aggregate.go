@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	aggregateLimit    = 10
+	maxAggregateLimit = 100
+)
+
+func (s *Server) HandleAggregateLogs(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+	if container.LogFormat != models.LogFormatJSON {
+		s.jsonError(w, "Aggregation needs the json log format", http.StatusBadRequest)
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	if !db.ValidAggregateField(field) {
+		s.jsonError(w, "Invalid field", http.StatusBadRequest)
+		return
+	}
+
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		s.jsonError(w, "Invalid since time", http.StatusBadRequest)
+		return
+	}
+	until, err := parseTimeParam(r, "until")
+	if err != nil {
+		s.jsonError(w, "Invalid until time", http.StatusBadRequest)
+		return
+	}
+
+	limit := aggregateLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			s.jsonError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxAggregateLimit)
+	}
+
+	agg, err := s.db.AggregateField(db.LogQuery{TrackedContainerID: container.ID, Since: since, Until: until, Limit: limit}, field)
+	if err != nil {
+		log.Printf("[backend] Failed to aggregate logs of %s by %s: %v", container.ContainerName, field, err)
+		s.jsonError(w, "Failed to aggregate logs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agg)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+)
+
+func TestBinaryLinesAreDropped(t *testing.T) {
+	lines := []string{
+		"GET /healthz 200",
+		"\x00\x01\x02\x03\xff\xfe\x7fPK\x03\x04\x00\x00\x08",
+		"\x1b[32mINFO\x1b[0m server started\tport=8080",
+		"héllo wörld ✓",
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"flushed\x00 in 5ms",
+	}
+
+	for _, tc := range []struct {
+		threshold float64
+		want      int
+	}{
+		{0, len(lines)},
+		// Only the zip and PNG headers are mostly binary.
+		{0.3, len(lines) - 2},
+	} {
+		t.Run(fmt.Sprintf("threshold=%g", tc.threshold), func(t *testing.T) {
+			s, fake := newTestServer(t, Config{BinaryThreshold: tc.threshold})
+			container := addTestContainer(t, s, fake, "sidecar")
+			fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+				return lineStream(time.Now(), lines...), nil
+			}
+
+			s.collectLogsForContainer(context.Background(), container, false, func() {})
+			stored := storedMessages(t, s, container.ID)
+			if len(stored) != tc.want {
+				t.Fatalf("stored %q, want %d lines", stored, tc.want)
+			}
+			if tc.threshold > 0 {
+				for _, message := range stored {
+					if strings.Contains(message, "PK") || strings.Contains(message, "PNG") {
+						t.Fatalf("stored binary remnant %q", message)
+					}
+				}
+			}
+		})
+	}
+}
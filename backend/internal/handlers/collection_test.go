@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+)
+
+func TestSlowFollowStreamDoesNotBlockOtherContainers(t *testing.T) {
+	s, fake := newTestServer(t, Config{CollectionWorkers: 1})
+	slow := addTestContainer(t, s, fake, "slow")
+	fast := addTestContainer(t, s, fake, "fast")
+
+	opened := make(chan struct{})
+	fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+		if containerID == slow.ContainerID {
+			// A follow stream that stays open without sending anything.
+			ch := make(chan docker.LogMessage)
+			go func() {
+				<-ctx.Done()
+				close(ch)
+			}()
+			close(opened)
+			return ch, nil
+		}
+		return lineStream(time.Now(), "fast line"), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.startCollection(ctx, slow)
+	<-opened
+
+	s.collectLogsForAllContainers(ctx)
+	waitFor(t, "the fast container's logs", func() bool {
+		return len(storedMessages(t, s, fast.ID)) == 1
+	})
+
+	// The running collector still guards against a second stream.
+	s.collectLogsForAllContainers(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n := fake.streams(slow.ContainerID); n != 1 {
+		t.Fatalf("slow container streams = %d, want 1", n)
+	}
+}
+
+func TestMaxStreams(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		maxStreams int
+		following  int
+	}{
+		{"unlimited", 0, 3},
+		{"one", 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, fake := newTestServer(t, Config{CollectionWorkers: 1, MaxStreams: tc.maxStreams})
+
+			following := make(chan string, 3)
+			fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+				ch := make(chan docker.LogMessage)
+				if opts.NoFollow {
+					close(ch)
+					return ch, nil
+				}
+				following <- containerID
+				go func() {
+					<-ctx.Done()
+					close(ch)
+				}()
+				return ch, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for _, name := range []string{"a", "b", "c"} {
+				s.startCollection(ctx, addTestContainer(t, s, fake, name))
+			}
+
+			open := make(map[string]bool)
+			timeout := time.After(2 * time.Second)
+			for len(open) < tc.following {
+				select {
+				case id := <-following:
+					open[id] = true
+				case <-timeout:
+					t.Fatalf("%d follow streams open, want %d", len(open), tc.following)
+				}
+			}
+			select {
+			case id := <-following:
+				t.Fatalf("unexpected follow stream for %s beyond %d", id, tc.following)
+			case <-time.After(100 * time.Millisecond):
+			}
+			waitFor(t, "the following count", func() bool { return s.followingStreams() == tc.following })
+		})
+	}
+}
+
+func TestCrashLoopingContainerIsNotCollectedUntilSettled(t *testing.T) {
+	s, fake := newTestServer(t, Config{CollectionWorkers: 1})
+	container := addTestContainer(t, s, fake, "looping")
+	if err := s.db.SetCrashLooping(container.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.collectLogsForAllContainers(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n := fake.streams(container.ContainerID); n != 0 {
+		t.Fatalf("streams while crash looping = %d, want 0", n)
+	}
+
+	containers, err := s.db.GetAllContainers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.settleCrashLoops(ctx, containers) {
+		t.Fatal("crash loop did not settle")
+	}
+	waitFor(t, "collection after the crash loop settled", func() bool {
+		return fake.streams(container.ContainerID) > 0
+	})
+}
+
+func TestCollectorReconnectsFlakyStream(t *testing.T) {
+	s, fake := newTestServer(t, Config{CollectionWorkers: 1, ReconnectWindow: 10 * time.Second})
+	container := addTestContainer(t, s, fake, "flaky")
+
+	start := time.Now()
+	var sinces []time.Time
+	fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+		sinces = append(sinces, opts.Since)
+		ch := make(chan docker.LogMessage, 2)
+		if len(sinces) == 1 {
+			// Breaks off after a line, like a dropped connection.
+			ch <- docker.LogMessage{Log: "before the drop", Timestamp: start, Stream: "stdout"}
+			ch <- docker.LogMessage{Err: errors.New("connection reset by peer")}
+			close(ch)
+			return ch, nil
+		}
+		ch <- docker.LogMessage{Log: "after the drop", Timestamp: start.Add(time.Millisecond), Stream: "stdout"}
+		go func() {
+			<-ctx.Done()
+			close(ch)
+		}()
+		return ch, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.collectLogsForContainer(ctx, container, true, func() {})
+		close(done)
+	}()
+
+	waitFor(t, "the line after the drop", func() bool {
+		return len(storedMessages(t, s, container.ID)) == 2
+	})
+	cancel()
+	<-done
+
+	if got := storedMessages(t, s, container.ID); got[0] != "before the drop" || got[1] != "after the drop" {
+		t.Fatalf("stored %v, want both lines in order", got)
+	}
+	if len(sinces) != 2 {
+		t.Fatalf("streams = %d, want the first and one reconnect", len(sinces))
+	}
+	if !sinces[1].After(sinces[0]) {
+		t.Fatalf("reconnected from %s, want after the first stream's %s", sinces[1], sinces[0])
+	}
+}
+
+func TestNameLookupErrorSkipsStream(t *testing.T) {
+	s, fake := newTestServer(t, Config{CollectionWorkers: 1})
+	container := addTestContainer(t, s, fake, "api")
+	fake.findErr = errors.New("daemon unreachable")
+
+	s.collectLogsForContainer(context.Background(), container, true, func() {
+		t.Error("opened called without a stream")
+	})
+	if n := fake.streams(container.ContainerID); n != 0 {
+		t.Fatalf("streams = %d, want none against the stale ID", n)
+	}
+	if !s.inBackoff(container.ID) {
+		t.Fatal("container is not backing off after the failed lookup")
+	}
+
+	// The collection pass leaves it alone while it backs off.
+	s.collectLogsForAllContainers(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	if n := fake.streams(container.ContainerID); n != 0 {
+		t.Fatalf("streams during backoff = %d, want none", n)
+	}
+}
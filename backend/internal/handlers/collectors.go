@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	collectorWaiting   = "waiting"
+	collectorFollowing = "following"
+	collectorPolling   = "polling"
+)
+
+// collector is the registry entry for a running collection goroutine. The
+// entry doubles as the guard against collecting a container twice.
+type collector struct {
+	containerName string
+	startedAt     time.Time
+	state         string
+	cancel        context.CancelFunc
+}
+
+// registerCollector claims container for a new collection goroutine and
+// returns the context it should run under, or a nil collector when one is
+// already running.
+func (s *Server) registerCollector(ctx context.Context, container models.Container) (context.Context, *collector) {
+	s.collectMu.Lock()
+	defer s.collectMu.Unlock()
+
+	if _, ok := s.collecting[container.ID]; ok {
+		return ctx, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &collector{
+		containerName: container.ContainerName,
+		startedAt:     time.Now(),
+		state:         collectorWaiting,
+		cancel:        cancel,
+	}
+	s.collecting[container.ID] = c
+	return ctx, c
+}
+
+func (s *Server) unregisterCollector(trackedContainerID string, c *collector) {
+	c.cancel()
+
+	s.collectMu.Lock()
+	defer s.collectMu.Unlock()
+	if s.collecting[trackedContainerID] == c {
+		delete(s.collecting, trackedContainerID)
+	}
+}
+
+// stopCollector cancels the collection goroutine of a container, if any.
+func (s *Server) stopCollector(trackedContainerID string) {
+	s.collectMu.Lock()
+	defer s.collectMu.Unlock()
+	if c, ok := s.collecting[trackedContainerID]; ok {
+		c.cancel()
+	}
+}
+
+func (s *Server) followingStreams() int {
+	s.collectMu.Lock()
+	defer s.collectMu.Unlock()
+
+	n := 0
+	for _, c := range s.collecting {
+		if c.state == collectorFollowing {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) setCollectorState(c *collector, state string) {
+	s.collectMu.Lock()
+	c.state = state
+	s.collectMu.Unlock()
+}
+
+func (s *Server) HandleListCollectors(w http.ResponseWriter, r *http.Request) {
+	s.collectMu.Lock()
+	collectors := make([]models.CollectorInfo, 0, len(s.collecting))
+	for id, c := range s.collecting {
+		collectors = append(collectors, models.CollectorInfo{
+			ContainerID:   id,
+			ContainerName: c.containerName,
+			State:         c.state,
+			StartedAt:     c.startedAt.Unix(),
+		})
+	}
+	s.collectMu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].StartedAt < collectors[j].StartedAt
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CollectorListResponse{Collectors: collectors})
+}
+
+func (s *Server) HandleStopCollector(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.collectMu.Lock()
+	c, ok := s.collecting[id]
+	s.collectMu.Unlock()
+	if !ok {
+		s.jsonError(w, "No collector running for container", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("[backend] Stopping collector for %s (running since %s)", c.containerName, c.startedAt.Format(time.RFC3339))
+	c.cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"stopped": true})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestContainersWSSendsInitialSnapshot(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	web := addTestContainer(t, s, fake, "web")
+	api := addTestContainer(t, s, fake, "api")
+
+	// Repeated connects catch a snapshot that depends on timing.
+	for i := 0; i < 20; i++ {
+		conn := dialWS(t, "/api/ws/containers", s.HandleWSContainers, "/api/ws/containers")
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		var hello, snapshot struct {
+			Type       string             `json:"type"`
+			Containers []models.Container `json:"containers"`
+		}
+		if err := conn.ReadJSON(&hello); err != nil || hello.Type != "hello" {
+			t.Fatalf("first message = %q, %v, want hello", hello.Type, err)
+		}
+		if err := conn.ReadJSON(&snapshot); err != nil || snapshot.Type != "containers" {
+			t.Fatalf("second message = %q, %v, want the containers snapshot", snapshot.Type, err)
+		}
+		ids := map[string]bool{}
+		for _, c := range snapshot.Containers {
+			ids[c.ID] = true
+		}
+		if len(ids) != 2 || !ids[web.ID] || !ids[api.ID] {
+			t.Fatalf("snapshot = %+v, want web and api", snapshot.Containers)
+		}
+		conn.Close()
+	}
+}
+
+func TestUnchangedRunningContainersSendNoDelta(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	web := addTestContainer(t, s, fake, "web")
+	api := addTestContainer(t, s, fake, "api")
+	for _, c := range []models.Container{web, api} {
+		if err := s.db.UpdateContainerStatus(c.ID, "running"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.db.SetStartedAt(c.ID, time.Now().Add(-time.Hour).Unix()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn := dialWS(t, "/api/ws/containers", s.HandleWSContainers, "/api/ws/containers?mode=delta")
+	readWS(t, conn, "containers")
+
+	broadcast := func() {
+		containers, err := s.db.GetAllContainers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.hub.BroadcastContainers(containers)
+	}
+
+	// Running containers read a second later are unchanged, so this pass
+	// sends nothing and the next message is the delta for api alone.
+	time.Sleep(1100 * time.Millisecond)
+	broadcast()
+	if err := s.db.UpdateContainerStatus(api.ID, "exited"); err != nil {
+		t.Fatal(err)
+	}
+	broadcast()
+
+	var delta struct {
+		Type    string             `json:"type"`
+		Changed []models.Container `json:"changed"`
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&delta); err != nil {
+		t.Fatal(err)
+	}
+	if delta.Type != "containers_delta" || len(delta.Changed) != 1 || delta.Changed[0].ID != api.ID {
+		t.Fatalf("got %s changing %+v, want a delta for api only", delta.Type, delta.Changed)
+	}
+}
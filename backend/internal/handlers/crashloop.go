@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker-logs-viewer/backend/internal/websocket"
+)
+
+const (
+	crashLoopSwaps  = 5
+	crashLoopWindow = 10 * time.Minute
+)
+
+// recordSwap notes a swap of container and reports whether it is crash
+// looping: swapped crashLoopSwaps times within crashLoopWindow. entered is true
+// only for the swap that started the loop.
+func (s *Server) recordSwap(container models.Container) (looping, entered bool) {
+	s.crashMu.Lock()
+	defer s.crashMu.Unlock()
+
+	now := time.Now()
+	swaps := s.swapTimes[container.ID]
+	for len(swaps) > 0 && now.Sub(swaps[0]) > crashLoopWindow {
+		swaps = swaps[1:]
+	}
+	swaps = append(swaps, now)
+	if len(swaps) > crashLoopSwaps {
+		swaps = swaps[1:]
+	}
+	s.swapTimes[container.ID] = swaps
+
+	if container.CrashLooping {
+		return true, false
+	}
+	if len(swaps) < crashLoopSwaps {
+		return false, false
+	}
+
+	if err := s.db.SetCrashLooping(container.ID, true); err != nil {
+		log.Printf("[backend] Failed to mark %s as crash looping: %v", container.ContainerName, err)
+	}
+	return true, true
+}
+
+// settleCrashLoops ends the crash loop of every container that has not been
+// swapped for crashLoopWindow and reports whether any did.
+func (s *Server) settleCrashLoops(ctx context.Context, containers []models.Container) bool {
+	s.crashMu.Lock()
+	defer s.crashMu.Unlock()
+
+	settled := false
+	for _, container := range containers {
+		swaps := s.swapTimes[container.ID]
+		if len(swaps) > 0 && time.Since(swaps[len(swaps)-1]) <= crashLoopWindow {
+			continue
+		}
+		delete(s.swapTimes, container.ID)
+		if !container.CrashLooping {
+			continue
+		}
+
+		if err := s.db.SetCrashLooping(container.ID, false); err != nil {
+			log.Printf("[backend] Failed to clear crash loop state of %s: %v", container.ContainerName, err)
+			continue
+		}
+		settled = true
+		log.Printf("[backend] %s has not restarted for %s, resuming normal swap handling", container.ContainerName, crashLoopWindow)
+		s.hub.BroadcastToContainer(container.ID, websocket.NewStatusMessage("crash_loop_ended"))
+		s.startCollection(ctx, container)
+	}
+	return settled
+}
+
+func (s *Server) reportCrashLoop(ctx context.Context, container models.Container, newID string, timestamp int64) {
+	log.Printf("[backend] %s was replaced %d times within %s, treating it as crash looping", container.ContainerName, crashLoopSwaps, crashLoopWindow)
+	s.hub.BroadcastToContainer(container.ID, websocket.NewStatusMessage("crash_looping"))
+
+	if s.config.QuietSystemLogs {
+		return
+	}
+	entry, err := s.addSystemLog(ctx, container.ID, newID, timestamp,
+		fmt.Sprintf("Container is crash looping: replaced %d times within %s; further swaps are not reported until it has run for %s", crashLoopSwaps, crashLoopWindow, crashLoopWindow))
+	if err != nil {
+		log.Printf("[backend] Failed to add system log: %v", err)
+		return
+	}
+	s.broadcastLog(container, entry)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const diskFullCheckInterval = 30 * time.Second
+
+var errIngestionPaused = errors.New("log ingestion paused: database disk is full")
+
+// storeLog persists a log line unless ingestion is paused for a full disk, and
+// wakes long-poll waiters on success. A disk-full error from the insert pauses
+// ingestion and wakes the recovery loop.
+func (s *Server) storeLog(ctx context.Context, entry *models.LogEntry) error {
+	if s.diskFull.Load() {
+		return errIngestionPaused
+	}
+
+	err := s.db.AddLog(ctx, entry)
+	if err != nil && db.IsDiskFull(err) {
+		if !s.diskFull.Swap(true) {
+			s.diskFullSince.Store(time.Now().Unix())
+			log.Printf("[backend] Database disk is full, pausing log ingestion: %v", err)
+			select {
+			case s.diskFullWake <- struct{}{}:
+			default:
+			}
+		}
+		return errIngestionPaused
+	}
+	if err == nil {
+		s.logNotify.notify(entry.TrackedContainerID)
+	}
+	return err
+}
+
+// diskFullWatcher frees space once per full-disk episode and resumes
+// ingestion as soon as a probe write succeeds.
+func (s *Server) diskFullWatcher(ctx context.Context) {
+	ticker := time.NewTicker(diskFullCheckInterval)
+	defer ticker.Stop()
+
+	pruned := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.diskFullWake:
+		case <-ticker.C:
+		}
+
+		if !s.diskFull.Load() {
+			pruned = false
+			continue
+		}
+
+		if !pruned && s.config.DiskFullPrunePercent > 0 {
+			n, err := s.db.RetentionManager().EmergencyPrune(ctx, s.config.DiskFullPrunePercent)
+			if err != nil {
+				log.Printf("[backend] Emergency retention failed after deleting %d logs: %v", n, err)
+			} else {
+				pruned = true
+				log.Printf("[backend] Emergency retention deleted %d logs", n)
+			}
+		}
+
+		if err := s.db.ProbeWrite(ctx); err != nil {
+			if !db.IsDiskFull(err) {
+				log.Printf("[backend] Failed to probe database: %v", err)
+			}
+			continue
+		}
+
+		since := time.Unix(s.diskFullSince.Load(), 0)
+		s.diskFull.Store(false)
+		pruned = false
+		log.Printf("[backend] Database is writable again after %s, resuming log ingestion", time.Since(since).Round(time.Second))
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker/docker/api/types"
+)
+
+// dockerAPI is the part of docker.DockerClient the server uses, so tests can
+// stand in for a daemon.
+type dockerAPI interface {
+	ListContainers(ctx context.Context) ([]types.Container, error)
+	ListContainersInfo(ctx context.Context) ([]docker.ContainerInfo, error)
+	FindContainerByName(ctx context.Context, name string) (*types.Container, error)
+	FindContainerByImage(ctx context.Context, image string) (*types.Container, []docker.ContainerInfo, error)
+	SuggestContainers(ctx context.Context, name string, limit int) ([]docker.ContainerInfo, error)
+	ResolveContainer(ctx context.Context, name string) (*docker.ResolveResult, error)
+	InspectContainer(ctx context.Context, containerID string) (*types.ContainerJSON, error)
+	StreamContainerLogs(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error)
+	OldestLogTimestamp(ctx context.Context, containerID string) (time.Time, error)
+	PingDocker(ctx context.Context) error
+	DaemonHost() string
+}
+
+// dockerFor returns the client for the daemon a container lives on: the
+// server's own unless the container was added with a dockerHost.
+func (s *Server) dockerFor(container models.Container) dockerAPI {
+	if container.DockerHost == "" {
+		return s.docker
+	}
+	dc, err := s.dockerForHost(context.Background(), container.DockerHost)
+	if err != nil {
+		log.Printf("[backend] Failed to get docker client for %s: %v", container.DockerHost, err)
+		// Calls on it fail with "docker client not initialized", which the
+		// callers already handle like an unreachable daemon.
+		return &docker.DockerClient{}
+	}
+	return dc
+}
+
+// dockerHostAllowed limits dockerHost to local sockets unless the address was
+// given with -docker-hosts, so the API cannot be used to reach arbitrary
+// hosts from the server.
+func (s *Server) dockerHostAllowed(host string) bool {
+	return strings.HasPrefix(host, "unix://") || slices.Contains(s.config.DockerHosts, host)
+}
+
+// dockerForHost returns the pooled client for host, creating it on first use.
+// A client is only pooled once its daemon has answered, so a typo or a daemon
+// that is down is retried on the next call rather than remembered.
+func (s *Server) dockerForHost(ctx context.Context, host string) (*docker.DockerClient, error) {
+	s.dockerHostsMu.Lock()
+	dc, ok := s.dockerHosts[host]
+	s.dockerHostsMu.Unlock()
+	if ok {
+		return dc, nil
+	}
+
+	if !s.dockerHostAllowed(host) {
+		return nil, fmt.Errorf("Invalid dockerHost: only unix:// sockets and hosts listed in -docker-hosts are allowed")
+	}
+	dc, err := docker.NewDockerClientForHost(host)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dockerHost: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := dc.PingDocker(ctx); err != nil {
+		dc.Close()
+		return nil, fmt.Errorf("Docker daemon at %s is not reachable: %v", host, err)
+	}
+
+	s.dockerHostsMu.Lock()
+	defer s.dockerHostsMu.Unlock()
+	if pooled, ok := s.dockerHosts[host]; ok {
+		// Another caller got there first.
+		dc.Close()
+		return pooled, nil
+	}
+	s.dockerHosts[host] = dc
+	return dc, nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDockerHostMustBeAllowed(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	if _, err := s.dockerForHost(context.Background(), "tcp://10.0.0.5:2375"); err == nil {
+		t.Fatal("tcp dockerHost not listed in -docker-hosts was accepted")
+	}
+	if len(s.dockerHosts) != 0 {
+		t.Fatalf("pooled %d clients, want none", len(s.dockerHosts))
+	}
+}
+
+func TestDockerHostPooledOnlyOnceReachable(t *testing.T) {
+	var up atomic.Bool
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Api-Version", "1.41")
+		w.Write([]byte("OK"))
+	}))
+	defer daemon.Close()
+	host := "tcp://" + strings.TrimPrefix(daemon.URL, "http://")
+
+	s, _ := newTestServer(t, Config{DockerHosts: []string{host}})
+	if _, err := s.dockerForHost(context.Background(), host); err == nil {
+		t.Fatal("unreachable daemon was accepted")
+	}
+	if len(s.dockerHosts) != 0 {
+		t.Fatal("client for an unreachable daemon was pooled")
+	}
+
+	up.Store(true)
+	dc, err := s.dockerForHost(context.Background(), host)
+	if err != nil {
+		t.Fatalf("daemon is up: %v", err)
+	}
+	if s.dockerHosts[host] != dc {
+		t.Fatal("client was not pooled once its daemon answered")
+	}
+}
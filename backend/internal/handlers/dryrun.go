@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// plannedContainer is the container AddContainer would store for req, with
+// the same defaults, but without an ID since nothing is inserted.
+func plannedContainer(req *models.AddContainerRequest, containerID, containerName, serverName, status string) models.Container {
+	source := req.Source
+	if source == "" {
+		source = models.SourceDocker
+	}
+	metadata := req.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	return models.Container{
+		ContainerID:     containerID,
+		ContainerName:   containerName,
+		Alias:           req.Alias,
+		Status:          status,
+		MaxPeriod:       req.MaxPeriod,
+		MaxLines:        req.MaxLines,
+		ServerName:      serverName,
+		ExpectedMaxGap:  req.ExpectedMaxGap,
+		Metadata:        metadata,
+		Source:          source,
+		Namespace:       req.Namespace,
+		Pod:             req.Pod,
+		PodContainer:    req.PodContainer,
+		LogDetails:      req.LogDetails,
+		DetailKeys:      req.DetailKeys,
+		LogFormat:       req.LogFormat,
+		CollectStdout:   req.CollectStdout == nil || *req.CollectStdout,
+		CollectStderr:   req.CollectStderr == nil || *req.CollectStderr,
+		InitialLines:    req.InitialLines,
+		SampleRate:      max(req.SampleRate, 1),
+		Transforms:      req.Transforms,
+		InlineRetention: req.InlineRetention == nil || *req.InlineRetention,
+		SyslogForward:   req.SyslogForward,
+		DockerHost:      req.DockerHost,
+		JSONFields:      req.JSONFields,
+
+		RetentionUnlimited: req.MaxPeriod <= 0 && req.MaxLines <= 0,
+	}
+}
+
+// writeDryRun answers an add with ?dryRun=true, noting things that are valid
+// but probably not what the caller meant.
+func (s *Server) writeDryRun(w http.ResponseWriter, container models.Container) {
+	var warnings []string
+	if container.Status != "" && container.Status != "running" {
+		warnings = append(warnings, fmt.Sprintf("Container is %s, so there are no new logs to collect until it starts", container.Status))
+	}
+	if container.RetentionUnlimited {
+		warnings = append(warnings, "Neither maxPeriod nor maxLines is set, so logs are kept until the container is removed")
+	}
+	if container.SyslogForward && s.config.Syslog == nil {
+		warnings = append(warnings, "syslogForward is set but the server was started without -syslog, so nothing is forwarded")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AddContainerResponse{
+		Container: container,
+		Success:   true,
+		DryRun:    true,
+		Warnings:  warnings,
+	})
+}
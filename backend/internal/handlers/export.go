@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// dockerTimestampFormat is the fixed-width RFC3339Nano layout `docker logs
+// --timestamps` prints, keeping trailing zeros so every line lines up.
+const dockerTimestampFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+const ansiReset = "\x1b[0m"
+
+var levelColors = map[string]string{
+	"ERROR": "\x1b[31m",
+	"WARN":  "\x1b[33m",
+}
+
+// textLine renders the message part of a docker format export line, with an
+// optional "[LEVEL] " prefix and ANSI color for error and warn lines.
+func textLine(entry models.LogEntry, levels, ansi bool) string {
+	level := entry.Level
+	if level == "" {
+		level = db.DetectLevel(entry.Message)
+	}
+
+	line := entry.Message
+	if levels {
+		line = "[" + level + "] " + line
+	}
+	if color, ok := levelColors[level]; ok && ansi {
+		line = color + line + ansiReset
+	}
+	return line
+}
+
+func (s *Server) HandleExportLogs(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		s.jsonError(w, "Invalid since time", http.StatusBadRequest)
+		return
+	}
+	until, err := parseTimeParam(r, "until")
+	if err != nil {
+		s.jsonError(w, "Invalid until time", http.StatusBadRequest)
+		return
+	}
+
+	var contentType, extension string
+	var write func(*bufio.Writer, models.LogEntry) error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "ndjson":
+		contentType, extension = "application/x-ndjson", "ndjson"
+		write = func(bw *bufio.Writer, entry models.LogEntry) error {
+			entry.Seq = 0
+			return json.NewEncoder(bw).Encode(entry)
+		}
+	case "docker":
+		contentType, extension = "text/plain; charset=utf-8", "log"
+		levels := r.URL.Query().Get("levels") == "true"
+		ansi := r.URL.Query().Get("ansi") == "true"
+		write = func(bw *bufio.Writer, entry models.LogEntry) error {
+			_, err := fmt.Fprintf(bw, "%s %s\n", time.Unix(0, entry.Timestamp).UTC().Format(dockerTimestampFormat), textLine(entry, levels, ansi))
+			return err
+		}
+	default:
+		s.jsonError(w, "Unknown export format", http.StatusBadRequest)
+		return
+	}
+
+	// Exports can run far longer than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, container.ContainerName, extension))
+
+	bw := bufio.NewWriter(w)
+	err = s.db.ExportLogs(r.Context(), db.LogQuery{TrackedContainerID: container.ID, Since: since, Until: until}, func(entry models.LogEntry) error {
+		return write(bw, entry)
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("[backend] Failed to export logs for %s: %v", container.ContainerName, err)
+	}
+}
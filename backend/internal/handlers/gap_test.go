@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func addGapContainer(t *testing.T, s *Server, fake *fakeDocker, name string) models.Container {
+	t.Helper()
+	id := dockerID(name)
+	fake.mu.Lock()
+	fake.containers[name] = id
+	fake.mu.Unlock()
+
+	container, err := s.db.AddContainer(&models.AddContainerRequest{Name: name, ExpectedMaxGap: 1}, id, name, "test")
+	if err != nil {
+		t.Fatalf("add container: %v", err)
+	}
+	if err := s.db.UpdateContainerStatus(container.ID, "running"); err != nil {
+		t.Fatal(err)
+	}
+	return *container
+}
+
+func gapAlerts(t *testing.T, s *Server, trackedContainerID string) int {
+	t.Helper()
+	alerts := 0
+	for _, message := range storedMessages(t, s, trackedContainerID) {
+		if strings.HasPrefix(message, "[SYSTEM] No logs received") {
+			alerts++
+		}
+	}
+	return alerts
+}
+
+func TestFollowedContainerLoggingSteadilyHasNoGap(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	busy := addGapContainer(t, s, fake, "busy")
+	quiet := addGapContainer(t, s, fake, "quiet")
+
+	fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+		ch := make(chan docker.LogMessage)
+		go func() {
+			defer close(ch)
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					if containerID != busy.ContainerID {
+						continue
+					}
+					select {
+					case ch <- docker.LogMessage{Log: "tick", Timestamp: now, Stream: "stdout"}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return ch, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.startCollection(ctx, busy)
+	s.startCollection(ctx, quiet)
+
+	// Keep checking past expected_max_gap while the follow streams stay open.
+	deadline := time.Now().Add(2500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		s.checkLogGaps(ctx)
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	if n := gapAlerts(t, s, busy.ID); n != 0 {
+		t.Fatalf("busy container got %d gap alerts while logging", n)
+	}
+	if n := gapAlerts(t, s, quiet.ID); n != 1 {
+		t.Fatalf("quiet container got %d gap alerts, want 1", n)
+	}
+}
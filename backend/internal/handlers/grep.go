@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const maxGrepContext = 100
+
+// grepWindow passes on the lines matching filter together with up to context
+// lines before and after each match, like grep -C. Lines sent as context of
+// one match are not held back for the next, so overlapping contexts never
+// send a line twice.
+type grepWindow struct {
+	filter  string
+	context int
+	before  []models.LogEntry
+	after   int
+}
+
+func newGrepWindow(filter string, context int) *grepWindow {
+	return &grepWindow{filter: strings.ToLower(filter), context: context}
+}
+
+// push returns the lines to send now that entry has arrived, oldest first.
+func (g *grepWindow) push(entry models.LogEntry) []models.LogEntry {
+	if strings.Contains(strings.ToLower(entry.Message), g.filter) {
+		lines := append(g.before, entry)
+		g.before = nil
+		g.after = g.context
+		return lines
+	}
+
+	if g.after > 0 {
+		g.after--
+		return []models.LogEntry{entry}
+	}
+
+	if g.context > 0 {
+		if len(g.before) == g.context {
+			g.before = g.before[1:]
+		}
+		g.before = append(g.before, entry)
+	}
+	return nil
+}
@@ -3,28 +3,122 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/docker-logs-viewer/backend/internal/db"
 	"github.com/docker-logs-viewer/backend/internal/docker"
+	"github.com/docker-logs-viewer/backend/internal/kubernetes"
 	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker-logs-viewer/backend/internal/syslog"
 	"github.com/docker-logs-viewer/backend/internal/websocket"
+	"github.com/docker/docker/api/types"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	ws "github.com/gorilla/websocket"
 )
 
+const (
+	listInspectWorkers = 8
+	maxHistoryLimit    = 5000
+	historyChunkSize   = 500
+	historyChunkBytes  = 256 * 1024
+	maxNameSuggestions = 5
+	maxAnnotationSize  = 4096
+	gapCheckInterval   = 30 * time.Second
+	statusInterval     = 2 * time.Second
+	collectionInterval = 5 * time.Second
+	maxReplayDelay     = 10 * time.Second
+	streamBackoffBase  = 5 * time.Second
+	streamBackoffMax   = 5 * time.Minute
+	reconnectDelayBase = time.Second
+	reconnectDelayMax  = 15 * time.Second
+	localTimeFormat    = "2006-01-02T15:04:05.000Z07:00"
+)
+
+type Config struct {
+	StaticPath           string
+	CollectionWorkers    int
+	MaxWSClients         int
+	BinaryThreshold      float64
+	WSMaxMessageSize     int
+	Kubernetes           *kubernetes.Client
+	ResumeTailAfter      time.Duration
+	ResumeTailLines      int
+	ResumeSkew           time.Duration
+	ReconnectWindow      time.Duration
+	Syslog               *syslog.Forwarder
+	QuietSystemLogs      bool
+	StatusWebhook        string
+	WebhookDebounce      time.Duration
+	ReadOnly             bool
+	BroadcastQueueSize   int
+	StreamIdleTimeout    time.Duration
+	DiskFullPrunePercent int
+	MaxStreams           int
+	UnknownAfterFailures int
+	ListInspectWorkers   int
+	DockerHosts          []string
+}
+
 type Server struct {
-	db         *db.SQLiteDB
-	docker     *docker.DockerClient
-	hub        *websocket.Hub
-	staticPath string
+	db            *db.SQLiteDB
+	docker        dockerAPI
+	kube          *kubernetes.Client
+	statusWebhook *statusWebhook
+	hub           *websocket.Hub
+	logNotify     *logNotifier
+	staticPath    string
+	config        Config
+	epoch         int64
+
+	collectSem  chan struct{}
+	streamSlots chan struct{}
+	collectMu   sync.Mutex
+	collecting  map[string]*collector
+
+	gapAlerted map[string]int64
+
+	// lastIngest is when each container last had a line read from one of
+	// its streams. last_log_timestamp only moves when a stream ends, so a
+	// followed container would otherwise look silent to the gap check.
+	lastIngestMu sync.Mutex
+	lastIngest   map[string]int64
+
+	crashMu   sync.Mutex
+	swapTimes map[string][]time.Time
+
+	backoffMu sync.Mutex
+	backoff   map[string]*streamBackoff
+
+	inspectMu       sync.Mutex
+	inspectFailures map[string]int
+
+	dockerHostsMu sync.Mutex
+	dockerHosts   map[string]*docker.DockerClient
+
+	lastStatusRun     atomic.Int64
+	lastCollectionRun atomic.Int64
+	lastListDuration  atomic.Int64
+
+	diskFull      atomic.Bool
+	diskFullSince atomic.Int64
+	diskFullWake  chan struct{}
+}
+
+type streamBackoff struct {
+	failures int
+	retryAt  time.Time
 }
 
 func getContainerBasePrefix(name string) string {
@@ -36,24 +130,67 @@ func getContainerBasePrefix(name string) string {
 	return name + "-"
 }
 
-func NewServer(database *db.SQLiteDB, dockerClient *docker.DockerClient, staticPath string) *Server {
+func NewServer(database *db.SQLiteDB, dockerClient *docker.DockerClient, cfg Config) *Server {
+	workers := cfg.CollectionWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if cfg.ListInspectWorkers <= 0 {
+		cfg.ListInspectWorkers = listInspectWorkers
+	}
+
+	var streamSlots chan struct{}
+	if cfg.MaxStreams > 0 {
+		streamSlots = make(chan struct{}, cfg.MaxStreams)
+	}
+
+	var webhook *statusWebhook
+	if cfg.StatusWebhook != "" {
+		webhook = newStatusWebhook(cfg.StatusWebhook, cfg.WebhookDebounce)
+	}
+
 	return &Server{
-		db:         database,
-		docker:     dockerClient,
-		hub:        websocket.NewHub(),
-		staticPath: staticPath,
+		db:              database,
+		docker:          dockerClient,
+		kube:            cfg.Kubernetes,
+		statusWebhook:   webhook,
+		hub:             websocket.NewHub(cfg.WSMaxMessageSize, cfg.BroadcastQueueSize),
+		logNotify:       newLogNotifier(),
+		staticPath:      cfg.StaticPath,
+		config:          cfg,
+		epoch:           time.Now().UnixNano(),
+		collectSem:      make(chan struct{}, workers),
+		streamSlots:     streamSlots,
+		collecting:      make(map[string]*collector),
+		gapAlerted:      make(map[string]int64),
+		lastIngest:      make(map[string]int64),
+		backoff:         make(map[string]*streamBackoff),
+		swapTimes:       make(map[string][]time.Time),
+		inspectFailures: make(map[string]int),
+		dockerHosts:     make(map[string]*docker.DockerClient),
+		diskFullWake:    make(chan struct{}, 1),
 	}
 }
 
 func (s *Server) Run(ctx context.Context) {
+	now := time.Now().UnixNano()
+	s.lastStatusRun.Store(now)
+	s.lastCollectionRun.Store(now)
+
 	go s.hub.Run()
 	go s.containerWatcher(ctx)
 	go s.logCollectionWatcher(ctx)
+	go s.gapWatcher(ctx)
+	go s.diskFullWatcher(ctx)
+	if s.config.Syslog != nil {
+		go s.config.Syslog.Run(ctx)
+	}
 	log.Printf("[backend] Server initialized")
 }
 
 func (s *Server) containerWatcher(ctx context.Context) {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(statusInterval)
 	defer ticker.Stop()
 
 	for {
@@ -62,12 +199,13 @@ func (s *Server) containerWatcher(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.checkContainerUpdates(ctx)
+			s.lastStatusRun.Store(time.Now().UnixNano())
 		}
 	}
 }
 
 func (s *Server) logCollectionWatcher(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(collectionInterval)
 	defer ticker.Stop()
 
 	for {
@@ -76,8 +214,92 @@ func (s *Server) logCollectionWatcher(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.collectLogsForAllContainers(ctx)
+			s.lastCollectionRun.Store(time.Now().UnixNano())
+		}
+	}
+}
+
+func (s *Server) gapWatcher(ctx context.Context) {
+	ticker := time.NewTicker(gapCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkLogGaps(ctx)
+		}
+	}
+}
+
+func (s *Server) checkLogGaps(ctx context.Context) {
+	containers, err := s.db.GetAllContainers()
+	if err != nil {
+		log.Printf("[backend] Failed to get containers for gap check: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, container := range containers {
+		if container.ExpectedMaxGap <= 0 || container.Status != "running" {
+			delete(s.gapAlerted, container.ID)
+			continue
+		}
+
+		lastLogTs, err := s.db.GetLastLogTimestamp(container.ID)
+		if err != nil {
+			log.Printf("[backend] Failed to get last log timestamp: %v", err)
+			continue
+		}
+
+		lastActivity := max(lastLogTs, time.Unix(container.SwappedAt, 0).UnixNano(), s.lastIngestTime(container.ID))
+		silence := now.Sub(time.Unix(0, lastActivity))
+		maxGap := time.Duration(container.ExpectedMaxGap) * time.Second
+		if silence <= maxGap {
+			delete(s.gapAlerted, container.ID)
+			continue
+		}
+
+		if s.gapAlerted[container.ID] == lastActivity {
+			continue
 		}
+		s.gapAlerted[container.ID] = lastActivity
+
+		log.Printf("[backend] No logs from %s for %s", container.ContainerName, silence.Round(time.Second))
+		entry, err := s.addSystemLog(ctx, container.ID, container.ContainerID, now.UnixNano(),
+			fmt.Sprintf("No logs received for %s (expected at most %s)", silence.Round(time.Second), maxGap))
+		if err != nil {
+			log.Printf("[backend] Failed to add system log: %v", err)
+			continue
+		}
+		s.broadcastLog(container, entry)
+		s.hub.BroadcastToContainer(container.ID, websocket.NewStatusMessage("log_gap"))
+	}
+}
+
+// noteIngest records that a line of the container was read from its stream.
+func (s *Server) noteIngest(trackedContainerID string) {
+	s.lastIngestMu.Lock()
+	s.lastIngest[trackedContainerID] = time.Now().UnixNano()
+	s.lastIngestMu.Unlock()
+}
+
+func (s *Server) lastIngestTime(trackedContainerID string) int64 {
+	s.lastIngestMu.Lock()
+	defer s.lastIngestMu.Unlock()
+	return s.lastIngest[trackedContainerID]
+}
+
+func (s *Server) addSystemLog(ctx context.Context, trackedContainerID, containerID string, timestamp int64, message string) (models.LogEntry, error) {
+	entry := models.LogEntry{
+		ID:                 uuid.New().String(),
+		TrackedContainerID: trackedContainerID,
+		ContainerID:        containerID,
+		Timestamp:          timestamp,
+		Message:            "[SYSTEM] " + message,
 	}
+	return entry, s.storeLog(ctx, &entry)
 }
 
 func (s *Server) collectLogsForAllContainers(ctx context.Context) {
@@ -86,16 +308,118 @@ func (s *Server) collectLogsForAllContainers(ctx context.Context) {
 		log.Printf("[backend] Failed to get containers for log collection: %v", err)
 		return
 	}
+	if s.diskFull.Load() {
+		return
+	}
 
 	for _, container := range containers {
-		s.collectLogsForContainer(ctx, container)
+		// A crash looping container would get a new collector for every
+		// replacement; settleCrashLoops starts collecting it again.
+		if container.CrashLooping || s.inBackoff(container.ID) {
+			continue
+		}
+		s.startCollection(ctx, container)
 	}
 }
 
-func (s *Server) collectLogsForContainer(ctx context.Context, container models.Container) {
-	currentContainer, err := s.docker.FindContainerByName(ctx, container.ContainerName)
+func (s *Server) inBackoff(trackedContainerID string) bool {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	b, ok := s.backoff[trackedContainerID]
+	return ok && time.Now().Before(b.retryAt)
+}
+
+func (s *Server) recordStreamFailure(container models.Container) {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	b, ok := s.backoff[container.ID]
+	if !ok {
+		b = &streamBackoff{}
+		s.backoff[container.ID] = b
+	}
+	b.failures++
+
+	delay := streamBackoffBase << min(b.failures-1, 10)
+	if delay > streamBackoffMax {
+		delay = streamBackoffMax
+	}
+	b.retryAt = time.Now().Add(delay)
+
+	log.Printf("[backend] Backing off log stream for %s for %s after %d consecutive failures", container.ContainerName, delay, b.failures)
+}
+
+func (s *Server) recordStreamSuccess(container models.Container) {
+	s.backoffMu.Lock()
+	defer s.backoffMu.Unlock()
+
+	if b, ok := s.backoff[container.ID]; ok {
+		log.Printf("[backend] Log stream for %s recovered after %d consecutive failures", container.ContainerName, b.failures)
+		delete(s.backoff, container.ID)
+	}
+}
+
+func (s *Server) startCollection(ctx context.Context, container models.Container) {
+	ctx, c := s.registerCollector(ctx, container)
+	if c == nil {
+		return
+	}
+
+	go func() {
+		defer s.unregisterCollector(container.ID, c)
+
+		// With -max-streams, follow streams have their own slots and
+		// containers that miss one are polled with short reads, which take
+		// turns on the collection workers.
+		follow := s.streamSlots == nil
+		if s.streamSlots != nil {
+			select {
+			case s.streamSlots <- struct{}{}:
+				defer func() { <-s.streamSlots }()
+				follow = true
+			default:
+			}
+		}
+
+		select {
+		case s.collectSem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		// A follow stream runs for as long as its container does, so it only
+		// holds a worker until the stream is open; a poll holds it throughout.
+		var releaseOnce sync.Once
+		release := func() { releaseOnce.Do(func() { <-s.collectSem }) }
+		defer release()
+
+		if follow {
+			s.setCollectorState(c, collectorFollowing)
+			s.collectLogsForContainer(ctx, container, true, release)
+		} else {
+			s.setCollectorState(c, collectorPolling)
+			s.collectLogsForContainer(ctx, container, false, func() {})
+		}
+	}()
+}
+
+// collectLogsForContainer reads a container's new logs, following them when
+// follow is set. opened is called once the log stream is open.
+func (s *Server) collectLogsForContainer(ctx context.Context, container models.Container, follow bool, opened func()) {
+	if container.Source == models.SourceKubernetes {
+		s.collectKubernetesLogs(ctx, container, follow, opened)
+		return
+	}
+
+	dc := s.dockerFor(container)
+	currentContainer, err := dc.FindContainerByName(ctx, container.ContainerName)
 	if err != nil {
-		log.Printf("[backend] Failed to find container by name %s: %v", container.ContainerName, err)
+		// The stored ID may be stale, so streaming from it would just fail
+		// again every pass. Back off; the status check decides whether the
+		// daemon is unreachable for long enough to mark it unknown.
+		log.Printf("[backend] Failed to find container by name %s, skipping collection: %v", container.ContainerName, err)
+		s.recordStreamFailure(container)
+		return
 	}
 
 	currentContainerID := container.ContainerID
@@ -116,31 +440,197 @@ func (s *Server) collectLogsForContainer(ctx context.Context, container models.C
 		log.Printf("[backend] Failed to get last log timestamp: %v", err)
 	}
 
-	since := time.Now().Add(-1 * time.Hour)
-	if lastLogTs > 0 {
-		since = time.Unix(0, lastLogTs)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := s.resumeOptions(container, lastLogTs, follow)
+	// Polls come through every collection pass, so only check when a
+	// follow stream resumes, and not after a deliberate tail.
+	if follow && lastLogTs > 0 && opts.Tail == 0 {
+		s.checkLogRotation(ctx, container, currentContainerID, opts.Since)
+	}
+	logsChan, err := dc.StreamContainerLogs(ctx, currentContainerID, opts)
+	if err != nil {
+		log.Printf("[backend] Failed to start log stream for %s: %v", container.ContainerName, err)
+		s.recordStreamFailure(container)
+		return
+	}
+	s.recordStreamSuccess(container)
+	opened()
+
+	err = s.ingestLogs(ctx, container, logsChan)
+	if err != nil && follow && s.config.ReconnectWindow > 0 {
+		s.reconnectStream(ctx, container, currentContainerID, lastLogTs, err)
+	}
+}
+
+// reconnectStream reopens a follow stream that broke off, from the last stored
+// line, until one ends without an error or the reconnect window passes without
+// a reopened stream delivering logs.
+func (s *Server) reconnectStream(ctx context.Context, container models.Container, dockerID string, lastLogTs int64, streamErr error) {
+	deadline := time.Now().Add(s.config.ReconnectWindow)
+	delay := reconnectDelayBase
+	for streamErr != nil && ctx.Err() == nil && !s.diskFull.Load() {
+		if time.Now().Add(delay).After(deadline) {
+			log.Printf("[backend] Giving up reconnecting the log stream of %s after %s: %v", container.ContainerName, s.config.ReconnectWindow, streamErr)
+			return
+		}
+		log.Printf("[backend] Log stream of %s broke off, reconnecting in %s: %v", container.ContainerName, delay, streamErr)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectDelayMax)
+
+		ts, err := s.db.GetLastLogTimestamp(container.ID)
+		if err != nil {
+			log.Printf("[backend] Failed to get last log timestamp: %v", err)
+			ts = lastLogTs
+		}
+		if ts > lastLogTs {
+			// The last stream got logs through, so it was not a dead end.
+			lastLogTs = ts
+			deadline = time.Now().Add(s.config.ReconnectWindow)
+			delay = reconnectDelayBase
+		}
+
+		logsChan, err := s.dockerFor(container).StreamContainerLogs(ctx, dockerID, s.resumeOptions(container, lastLogTs, true))
+		if err != nil {
+			streamErr = err
+			continue
+		}
+		streamErr = s.ingestLogs(ctx, container, logsChan)
+	}
+}
+
+func (s *Server) collectKubernetesLogs(ctx context.Context, container models.Container, follow bool, opened func()) {
+	if s.kube == nil {
+		return
+	}
+
+	lastLogTs, err := s.db.GetLastLogTimestamp(container.ID)
+	if err != nil {
+		log.Printf("[backend] Failed to get last log timestamp: %v", err)
 	}
 
-	logsChan, err := s.docker.StreamContainerLogs(ctx, currentContainerID, since)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := s.resumeOptions(container, lastLogTs, follow)
+	logsChan, err := s.kube.StreamPodLogs(ctx, container.Namespace, container.Pod, container.PodContainer, opts)
 	if err != nil {
 		log.Printf("[backend] Failed to start log stream for %s: %v", container.ContainerName, err)
+		s.recordStreamFailure(container)
 		return
 	}
+	s.recordStreamSuccess(container)
+	opened()
+
+	s.ingestLogs(ctx, container, logsChan)
+}
+
+func selectDetails(details map[string]string, keys []string) map[string]string {
+	if len(details) == 0 || len(keys) == 0 {
+		return details
+	}
+
+	selected := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := details[key]; ok {
+			selected[key] = value
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return selected
+}
+
+func (s *Server) resumeOptions(container models.Container, lastLogTs int64, follow bool) docker.StreamOptions {
+	opts := docker.StreamOptions{
+		Since:    time.Now().Add(-1 * time.Hour),
+		Details:  container.LogDetails,
+		NoFollow: !follow,
+		NoStdout: !container.CollectStdout,
+		NoStderr: !container.CollectStderr,
+	}
+	if lastLogTs > 0 {
+		// Re-reading a window before the last stored line catches lines
+		// whose timestamps landed behind it; the ones already stored are
+		// dropped as duplicates.
+		opts.Since = time.Unix(0, lastLogTs).Add(-s.config.ResumeSkew)
+	}
+
+	gap := time.Since(opts.Since)
+	if s.config.ResumeTailAfter > 0 && s.config.ResumeTailLines > 0 && gap > s.config.ResumeTailAfter {
+		// Polled containers come through here every collection tick, so
+		// only announce it for follow streams. Since stays set so a poll
+		// never re-reads lines it already stored.
+		if follow {
+			log.Printf("[backend] %s was silent for %s, resuming from the last %d lines; older lines are skipped",
+				container.ContainerName, gap.Round(time.Second), s.config.ResumeTailLines)
+		}
+		opts.Tail = s.config.ResumeTailLines
+	}
+
+	return opts
+}
 
+// ingestLogs stores the lines of a stream until it ends and returns the
+// error the stream broke off with, if any.
+func (s *Server) ingestLogs(ctx context.Context, container models.Container, logsChan <-chan docker.LogMessage) error {
+	var streamErr error
 	var lastTimestamp int64
+	var repeats repeatCounter
+	transforms := containerTransforms(container)
+	sampler := newLogSampler(container.SampleRate)
+	defer func() { s.reportSampled(ctx, container, sampler, lastTimestamp) }()
+
 	for logEntry := range logsChan {
+		if logEntry.Err != nil {
+			streamErr = logEntry.Err
+			continue
+		}
 		entry := s.parseLogEntry(logEntry.Log, container.ContainerID, logEntry.Timestamp)
 		entry.TrackedContainerID = container.ID
+		entry.Message = transforms.apply(entry.Message)
 		if entry.Message == "" {
 			continue
 		}
-		if err := s.db.AddLog(ctx, &entry); err != nil {
+		s.noteIngest(container.ID)
+		entry.Stream = logEntry.Stream
+		// Resume from the stream's own timestamps; the log format may
+		// replace the entry's with one taken from the line.
+		streamTs := entry.Timestamp
+		if !sampler.keep() {
+			// Dropped lines still advance the resume point so they are not
+			// read again on the next stream.
+			lastTimestamp = max(lastTimestamp, streamTs)
+			if sampler.reportDue() {
+				s.reportSampled(ctx, container, sampler, lastTimestamp)
+			}
+			continue
+		}
+		entry.Details = selectDetails(logEntry.Details, container.DetailKeys)
+		applyLogFormat(&entry, container)
+		entry.Occurrence = repeats.occurrence(entry)
+		if err := s.storeLog(ctx, &entry); err != nil {
+			// Stop reading so the stream is cancelled; collection restarts
+			// from the last stored timestamp once there is space again.
+			if errors.Is(err, errIngestionPaused) {
+				break
+			}
+			if errors.Is(err, db.ErrDuplicateLog) {
+				lastTimestamp = max(lastTimestamp, streamTs)
+				continue
+			}
 			log.Printf("[backend] Failed to persist log for %s: %v", container.ContainerName, err)
 		} else {
-			if entry.Timestamp > lastTimestamp {
-				lastTimestamp = entry.Timestamp
-			}
-			s.hub.BroadcastToContainer(container.ID, websocket.NewLogMessage(entry))
+			lastTimestamp = max(lastTimestamp, streamTs)
+			s.broadcastLog(container, entry)
+			s.forwardLog(container, entry)
 		}
 	}
 	if lastTimestamp > 0 {
@@ -148,6 +638,15 @@ func (s *Server) collectLogsForContainer(ctx context.Context, container models.C
 			log.Printf("[backend] Failed to update last log timestamp: %v", err)
 		}
 	}
+	return streamErr
+}
+
+// forwardLog passes a stored line on to the syslog server when the container
+// has forwarding enabled.
+func (s *Server) forwardLog(container models.Container, entry models.LogEntry) {
+	if s.config.Syslog != nil && container.SyslogForward {
+		s.config.Syslog.Send(entry, displayName(container))
+	}
 }
 
 func (s *Server) checkContainerUpdates(ctx context.Context) {
@@ -164,6 +663,7 @@ func (s *Server) checkContainerUpdates(ctx context.Context) {
 	}
 
 	dockerMap := make(map[string]string)
+	stateByID := make(map[string]string, len(dockerContainers))
 	for _, c := range dockerContainers {
 		name := ""
 		if len(c.Names) > 0 {
@@ -171,97 +671,38 @@ func (s *Server) checkContainerUpdates(ctx context.Context) {
 		}
 		dockerMap[name] = c.ID
 		dockerMap[c.ID] = c.ID
+		stateByID[c.ID] = c.State
 	}
 
 	swappedContainers := make(map[string]bool)
 	for _, dbContainer := range containers {
+		// Containers on another daemon are not in this listing; the
+		// collector notices their replacements when it looks them up by name.
+		if dbContainer.Source == models.SourceKubernetes || dbContainer.DockerHost != "" {
+			continue
+		}
 		if _, exists := dockerMap[dbContainer.ContainerID]; !exists {
 			if newID, exists := dockerMap[dbContainer.ContainerName]; exists {
-				oldID := dbContainer.ContainerID
-				oldLastLogTs, err := s.db.SwapContainer(dbContainer.ContainerID, newID, dbContainer.ContainerName)
-				if err != nil {
-					log.Printf("[backend] Failed to swap container: %v", err)
-					continue
-				}
-
-				swapTimestamp := time.Now().UnixNano()
-				if oldLastLogTs > 0 {
-					swapTimestamp = oldLastLogTs + 1
-				}
-				systemLog := models.LogEntry{
-					ID:                 uuid.New().String(),
-					TrackedContainerID: dbContainer.ID,
-					ContainerID:        newID,
-					Timestamp:          swapTimestamp,
-					Message:            fmt.Sprintf("[SYSTEM] Container swapped from %s to %s", oldID[:12], newID[:12]),
-				}
-				if err := s.db.AddLog(ctx, &systemLog); err != nil {
-					log.Printf("[backend] Failed to add system log: %v", err)
-				}
-				s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewContainerSwappedMessage(newID, dbContainer.ContainerName))
-
-				updatedContainer, err := s.db.GetContainerByID(dbContainer.ID)
-				if err == nil && updatedContainer != nil {
-					bgCtx := context.Background()
-					go s.collectLogsForContainer(bgCtx, *updatedContainer)
-				}
-
-				logs, err := s.db.GetLogs(dbContainer.ID, 1000, nil)
-				if err != nil {
-					log.Printf("[backend] Failed to fetch logs after swap: %v", err)
-				} else {
-					s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewLogsBatchMessage(logs))
+				if s.swapContainer(ctx, dbContainer, newID, dbContainer.ContainerName) {
+					swappedContainers[dbContainer.ID] = true
 				}
-				swappedContainers[dbContainer.ID] = true
 				continue
 			}
 
 			basePrefix := getContainerBasePrefix(dbContainer.ContainerName)
 			for name, id := range dockerMap {
 				if strings.HasPrefix(name, basePrefix) {
-					oldID := dbContainer.ContainerID
-					oldLastLogTs, err := s.db.SwapContainer(dbContainer.ContainerID, id, name)
-					if err != nil {
-						log.Printf("[backend] Failed to swap container: %v", err)
-						continue
+					if s.swapContainer(ctx, dbContainer, id, name) {
+						swappedContainers[dbContainer.ID] = true
+						break
 					}
-
-					swapTimestamp := time.Now().UnixNano()
-					if oldLastLogTs > 0 {
-						swapTimestamp = oldLastLogTs + 1
-					}
-					systemLog := models.LogEntry{
-						ID:                 uuid.New().String(),
-						TrackedContainerID: dbContainer.ID,
-						ContainerID:        id,
-						Timestamp:          swapTimestamp,
-						Message:            fmt.Sprintf("[SYSTEM] Container swapped from %s to %s", oldID[:12], id[:12]),
-					}
-					if err := s.db.AddLog(ctx, &systemLog); err != nil {
-						log.Printf("[backend] Failed to add system log: %v", err)
-					}
-					s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewContainerSwappedMessage(id, name))
-
-					updatedContainer, err := s.db.GetContainerByID(dbContainer.ID)
-					if err == nil && updatedContainer != nil {
-						bgCtx := context.Background()
-						go s.collectLogsForContainer(bgCtx, *updatedContainer)
-					}
-
-					logs, err := s.db.GetLogs(dbContainer.ID, 1000, nil)
-					if err != nil {
-						log.Printf("[backend] Failed to fetch logs after swap: %v", err)
-					} else {
-						s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewLogsBatchMessage(logs))
-					}
-					swappedContainers[dbContainer.ID] = true
-					break
 				}
 			}
 		}
 	}
 
-	if len(swappedContainers) > 0 {
+	settled := s.settleCrashLoops(ctx, containers)
+	if settled || len(swappedContainers) > 0 {
 		containers, err = s.db.GetAllContainers()
 		if err != nil {
 			log.Printf("[backend] Failed to get containers after swap: %v", err)
@@ -269,36 +710,154 @@ func (s *Server) checkContainerUpdates(ctx context.Context) {
 		}
 	}
 
-	statusChanged := false
+	// Swaps and crash loop changes show up in the container list too.
+	statusChanged := settled || len(swappedContainers) > 0
 	for i := range containers {
 		container := &containers[i]
-		inspectCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
-		dockerContainer, err := s.docker.InspectContainer(inspectCtx, container.ContainerID)
-		cancel()
-
-		if err != nil {
-			if container.Status != "unknown" {
-				container.Status = "unknown"
-				statusChanged = true
-				if err := s.db.UpdateContainerStatus(container.ID, "unknown"); err != nil {
-					log.Printf("[backend] Failed to update container status: %v", err)
-				}
-			}
-			continue
+		newStatus, listed := stateByID[container.ContainerID]
+		if container.DockerHost != "" {
+			listed = false
+		}
+		var err error
+		if !listed {
+			inspectCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+			newStatus, err = s.containerStatus(inspectCtx, *container)
+			cancel()
 		}
 
-		newStatus := dockerContainer.State.Status
-		if container.Status != newStatus {
-			container.Status = newStatus
+		newStatus = s.statusAfterInspect(container, newStatus, err)
+		wasRunning := container.Status == "running"
+		if s.setContainerStatus(container, newStatus) {
+			statusChanged = true
+		}
+		if newStatus == "running" && (!wasRunning || container.StartedAt == 0) && s.refreshStartedAt(ctx, container) {
 			statusChanged = true
-			if err := s.db.UpdateContainerStatus(container.ID, newStatus); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
-			}
 		}
 	}
 
 	if statusChanged {
-		s.hub.Broadcast(websocket.NewContainersMessage(containers))
+		s.hub.BroadcastContainers(containers)
+	}
+}
+
+// swapContainer points a tracked container at its replacement. Viewers get
+// the stored history as a replacing batch before the new collector starts, so
+// its live lines always arrive after the batch.
+func (s *Server) swapContainer(ctx context.Context, dbContainer models.Container, newID, newName string) bool {
+	oldID := dbContainer.ContainerID
+	oldLastLogTs, err := s.db.SwapContainer(oldID, newID, newName)
+	if err != nil {
+		log.Printf("[backend] Failed to swap container: %v", err)
+		return false
+	}
+
+	swapTimestamp := time.Now().UnixNano()
+	if oldLastLogTs > 0 {
+		swapTimestamp = oldLastLogTs + 1
+	}
+
+	// While a container crash loops, each swap only repoints it; collection
+	// resumes once it settles.
+	looping, entered := s.recordSwap(dbContainer)
+	if entered {
+		s.reportCrashLoop(ctx, dbContainer, newID, swapTimestamp)
+	}
+	if looping {
+		s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewContainerSwappedMessage(newID, newName))
+		return true
+	}
+
+	if s.config.QuietSystemLogs {
+		log.Printf("[backend] Container %s swapped from %s to %s", dbContainer.ContainerName, oldID[:12], newID[:12])
+	} else if _, err := s.addSystemLog(ctx, dbContainer.ID, newID, swapTimestamp,
+		fmt.Sprintf("Container swapped from %s to %s", oldID[:12], newID[:12])); err != nil {
+		log.Printf("[backend] Failed to add system log: %v", err)
+	}
+	s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewContainerSwappedMessage(newID, newName))
+
+	logs, err := s.db.GetLogs(db.LogQuery{TrackedContainerID: dbContainer.ID, Limit: 1000})
+	if err != nil {
+		log.Printf("[backend] Failed to fetch logs after swap: %v", err)
+	} else {
+		s.hub.BroadcastToContainer(dbContainer.ID, websocket.NewSwapBatchMessage(logs, time.Now().UnixNano()))
+	}
+
+	updatedContainer, err := s.db.GetContainerByID(dbContainer.ID)
+	if err == nil && updatedContainer != nil {
+		s.startCollection(context.Background(), *updatedContainer)
+	}
+
+	return true
+}
+
+func (s *Server) setContainerStatus(container *models.Container, status string) bool {
+	if container.Status == status {
+		return false
+	}
+
+	oldStatus := container.Status
+	container.Status = status
+	if err := s.db.UpdateContainerStatus(container.ID, status); err != nil {
+		log.Printf("[backend] Failed to update container status: %v", err)
+	}
+	if s.statusWebhook != nil {
+		s.statusWebhook.Notify(*container, oldStatus, status)
+	}
+	return true
+}
+
+func (s *Server) containerStatus(ctx context.Context, container models.Container) (string, error) {
+	if container.Source == models.SourceKubernetes {
+		if s.kube == nil {
+			return "", fmt.Errorf("kubernetes source not configured")
+		}
+		return s.kube.PodStatus(ctx, container.Namespace, container.Pod, container.PodContainer)
+	}
+
+	dockerContainer, err := s.dockerFor(container).InspectContainer(ctx, container.ContainerID)
+	if err != nil {
+		return "", err
+	}
+	// Some daemon versions answer without a state.
+	if dockerContainer.ContainerJSONBase == nil || dockerContainer.State == nil || dockerContainer.State.Status == "" {
+		return "unknown", nil
+	}
+	return dockerContainer.State.Status, nil
+}
+
+// refreshStartedAt reads the start time of a container that just started (or
+// whose start time isn't known yet) so clients can show its uptime.
+func (s *Server) refreshStartedAt(ctx context.Context, container *models.Container) bool {
+	if container.Source == models.SourceKubernetes {
+		return false
+	}
+
+	inspectCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+	info, err := s.dockerFor(*container).InspectContainer(inspectCtx, container.ContainerID)
+	if err != nil || info.ContainerJSONBase == nil || info.State == nil {
+		return false
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, info.State.StartedAt)
+	if err != nil || startedAt.Unix() <= 0 || startedAt.Unix() == container.StartedAt {
+		return false
+	}
+
+	if err := s.db.SetStartedAt(container.ID, startedAt.Unix()); err != nil {
+		log.Printf("[backend] Failed to update container start time: %v", err)
+		return false
+	}
+	container.StartedAt = startedAt.Unix()
+	return true
+}
+
+func (s *Server) RequireWritable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.config.ReadOnly {
+			s.jsonError(w, "Server is in read-only mode", http.StatusForbidden)
+			return
+		}
+		next(w, r)
 	}
 }
 
@@ -318,33 +877,134 @@ func (s *Server) HandleAddContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
 		s.jsonError(w, "Container name is required", http.StatusBadRequest)
 		return
 	}
 
-	ctx := r.Context()
+	if req.MaxPeriod < 0 || req.MaxLines < 0 {
+		s.jsonError(w, "maxPeriod and maxLines must not be negative", http.StatusBadRequest)
+		return
+	}
 
-	container, err := s.docker.FindContainerByName(ctx, req.Name)
-	if err != nil {
-		log.Printf("[backend] Failed to find container: %v", err)
-		s.jsonError(w, "Failed to find container", http.StatusInternalServerError)
+	if req.ExpectedMaxGap < 0 {
+		s.jsonError(w, "Expected max gap must not be negative", http.StatusBadRequest)
 		return
 	}
 
-	if container == nil {
-		s.jsonError(w, "Container not found", http.StatusNotFound)
+	if err := validateMetadata(req.Metadata); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	containerName := ""
-	if len(container.Names) > 0 {
-		containerName = strings.TrimPrefix(container.Names[0], "/")
+	if !validLogFormat(req.LogFormat) {
+		s.jsonError(w, "Unknown log format", http.StatusBadRequest)
+		return
 	}
 
-	serverName := s.docker.DaemonHost()
-	if req.ServerName != "" {
-		serverName = req.ServerName
+	if req.CollectStdout != nil && req.CollectStderr != nil && !*req.CollectStdout && !*req.CollectStderr {
+		s.jsonError(w, "At least one of stdout and stderr must be collected", http.StatusBadRequest)
+		return
+	}
+
+	if req.InitialLines < 0 {
+		s.jsonError(w, "initialLines must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.SampleRate < 0 {
+		s.jsonError(w, "sampleRate must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	if !validJSONTimestampFormat(req.JSONTimestampFormat) {
+		s.jsonError(w, "Unknown JSON timestamp format", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := compileTransforms(req.Transforms); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Source {
+	case "", models.SourceDocker:
+	case models.SourceKubernetes:
+		if req.DockerHost != "" {
+			s.jsonError(w, "dockerHost only applies to Docker containers", http.StatusBadRequest)
+			return
+		}
+		s.addKubernetesContainer(w, r, &req)
+		return
+	default:
+		s.jsonError(w, "Unknown container source", http.StatusBadRequest)
+		return
+	}
+
+	if req.By == "" {
+		req.By = r.URL.Query().Get("by")
+	}
+
+	ctx := r.Context()
+
+	var dc dockerAPI = s.docker
+	if req.DockerHost != "" {
+		var err error
+		dc, err = s.dockerForHost(ctx, req.DockerHost)
+		if err != nil {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var container *types.Container
+	var err error
+	switch req.By {
+	case "", "name":
+		// Names copied from `docker ps --format '{{.Names}}'` or the API
+		// often keep Docker's leading slash.
+		req.Name = strings.TrimLeft(req.Name, "/")
+		if req.Name == "" {
+			s.jsonError(w, "Container name is required", http.StatusBadRequest)
+			return
+		}
+		container, err = dc.FindContainerByName(ctx, req.Name)
+	case "image":
+		var candidates []docker.ContainerInfo
+		container, candidates, err = dc.FindContainerByImage(ctx, req.Name)
+		if err == nil && len(candidates) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      "Several running containers use this image",
+				"candidates": candidates,
+			})
+			return
+		}
+	default:
+		s.jsonError(w, "Invalid match mode", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[backend] Failed to find container: %v", err)
+		s.jsonError(w, "Failed to find container", http.StatusInternalServerError)
+		return
+	}
+
+	if container == nil {
+		s.containerNotFound(w, r, dc, &req)
+		return
+	}
+
+	containerName := ""
+	if len(container.Names) > 0 {
+		containerName = strings.TrimPrefix(container.Names[0], "/")
+	}
+
+	serverName := dc.DaemonHost()
+	if req.ServerName != "" {
+		serverName = req.ServerName
 	}
 
 	existingContainers, err := s.db.GetAllContainers()
@@ -369,6 +1029,13 @@ func (s *Server) HandleAddContainer(w http.ResponseWriter, r *http.Request) {
 		alias = containerName
 	}
 
+	req.LogFormat = s.resolveLogFormat(ctx, dc, container.ID, req.LogFormat)
+
+	if r.URL.Query().Get("dryRun") == "true" {
+		s.writeDryRun(w, plannedContainer(&req, container.ID, containerName, serverName, container.State))
+		return
+	}
+
 	addedContainer, err := s.db.AddContainer(&req, container.ID, containerName, serverName)
 	if err != nil {
 		log.Printf("[backend] Failed to add container: %v", err)
@@ -376,8 +1043,87 @@ func (s *Server) HandleAddContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bgCtx := context.Background()
-	go s.collectLogsForContainer(bgCtx, *addedContainer)
+	s.startCollection(context.Background(), *addedContainer)
+	s.hub.BroadcastToContainer("containers", websocket.NewContainerAddedMessage(*addedContainer))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AddContainerResponse{
+		Container: *addedContainer,
+		Success:   true,
+	})
+}
+
+func (s *Server) addKubernetesContainer(w http.ResponseWriter, r *http.Request, req *models.AddContainerRequest) {
+	if s.kube == nil {
+		s.jsonError(w, "Kubernetes source is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	if req.Namespace == "" || req.Pod == "" {
+		s.jsonError(w, "Namespace and pod are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	status, err := s.kube.PodStatus(ctx, req.Namespace, req.Pod, req.PodContainer)
+	if err != nil {
+		log.Printf("[backend] Failed to find pod %s/%s: %v", req.Namespace, req.Pod, err)
+		s.jsonError(w, "Pod not found", http.StatusNotFound)
+		return
+	}
+
+	ref := "k8s/" + req.Namespace + "/" + req.Pod
+	if req.PodContainer != "" {
+		ref += "/" + req.PodContainer
+	}
+
+	serverName := "kubernetes"
+	if req.ServerName != "" {
+		serverName = req.ServerName
+	}
+
+	existingContainers, err := s.db.GetAllContainers()
+	if err != nil {
+		log.Printf("[backend] Failed to get existing containers: %v", err)
+	}
+
+	for _, c := range existingContainers {
+		if c.ContainerID == ref {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.AddContainerResponse{
+				Container: c,
+				Success:   true,
+				Message:   "Container already tracked",
+			})
+			return
+		}
+	}
+
+	if req.LogFormat == "" || req.LogFormat == models.LogFormatAuto {
+		req.LogFormat = models.LogFormatDocker
+	}
+
+	if (req.CollectStdout != nil && !*req.CollectStdout) || (req.CollectStderr != nil && !*req.CollectStderr) {
+		s.jsonError(w, "Kubernetes pods always collect both stdout and stderr", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("dryRun") == "true" {
+		s.writeDryRun(w, plannedContainer(req, ref, req.Pod, serverName, status))
+		return
+	}
+
+	addedContainer, err := s.db.AddContainer(req, ref, req.Pod, serverName)
+	if err != nil {
+		log.Printf("[backend] Failed to add container: %v", err)
+		s.jsonError(w, "Failed to add container", http.StatusInternalServerError)
+		return
+	}
+
+	s.startCollection(context.Background(), *addedContainer)
+	s.hub.BroadcastToContainer("containers", websocket.NewContainerAddedMessage(*addedContainer))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.AddContainerResponse{
@@ -386,36 +1132,90 @@ func (s *Server) HandleAddContainer(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func validateMetadata(metadata map[string]string) error {
+	for key := range metadata {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("Metadata keys must not be empty")
+		}
+	}
+	return nil
+}
+
 func (s *Server) HandleListContainers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	containers, err := s.db.GetAllContainers()
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "addedAt"
+	}
+	if !db.ValidContainerSort(sort) {
+		s.jsonError(w, "Invalid sort field", http.StatusBadRequest)
+		return
+	}
+
+	order := r.URL.Query().Get("order")
+	if order == "" {
+		order = "desc"
+		if sort != "addedAt" {
+			order = "asc"
+		}
+	}
+	if order != "asc" && order != "desc" {
+		s.jsonError(w, "Invalid sort order", http.StatusBadRequest)
+		return
+	}
+
+	var statuses []string
+	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
+		for _, status := range strings.Split(statusParam, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !db.ValidContainerStatus(status) {
+				s.jsonError(w, "Invalid status: "+status, http.StatusBadRequest)
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	includeArchived := r.URL.Query().Get("includeArchived") == "true"
+	containers, err := s.db.GetContainersSorted(sort, order == "desc", statuses, includeArchived)
 	if err != nil {
 		log.Printf("[backend] Failed to list containers: %v", err)
 		s.jsonError(w, "Failed to list containers", http.StatusInternalServerError)
 		return
 	}
 
+	sizes, err := s.db.GetContainerSizes()
+	if err != nil {
+		log.Printf("[backend] Failed to get container sizes: %v", err)
+	}
 	for i := range containers {
-		container := &containers[i]
-		inspectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		dockerContainer, err := s.docker.InspectContainer(inspectCtx, container.ContainerID)
-		cancel()
-		if err != nil {
-			container.Status = "unknown"
-			if err := s.db.UpdateContainerStatus(container.ID, "unknown"); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
-			}
-			continue
-		}
+		containers[i].LogBytes = sizes[containers[i].ID]
+		containers[i].RetentionUnlimited = containers[i].MaxPeriod <= 0 && containers[i].MaxLines <= 0
+	}
 
-		newStatus := dockerContainer.State.Status
-		if container.Status != newStatus {
-			container.Status = newStatus
-			if err := s.db.UpdateContainerStatus(container.ID, newStatus); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
+	start := time.Now()
+	done := s.inspectContainers(ctx, containers)
+	defer func() { s.lastListDuration.Store(int64(time.Since(start))) }()
+
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+		for i := range done {
+			if err := encoder.Encode(containers[i]); err != nil {
+				continue
+			}
+			if flusher != nil {
+				flusher.Flush()
 			}
 		}
+		return
+	}
+
+	// Every container reports once it is inspected.
+	for range containers {
+		<-done
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -424,6 +1224,68 @@ func (s *Server) HandleListContainers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Server) inspectContainers(ctx context.Context, containers []models.Container) <-chan int {
+	jobs := make(chan int)
+	done := make(chan int)
+
+	var wg sync.WaitGroup
+	for n := 0; n < min(s.config.ListInspectWorkers, len(containers)); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				s.refreshContainerStatus(ctx, &containers[i], 5*time.Second)
+				done <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range containers {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+// statusAfterInspect decides the status to record after an inspect. A failed
+// inspect only turns the status unknown after UnknownAfterFailures consecutive
+// failures, so a single slow response from a busy daemon does not flap it.
+// Only checkContainerUpdates calls it, so failures are counted once per pass
+// however many clients list containers in between.
+func (s *Server) statusAfterInspect(container *models.Container, status string, err error) string {
+	s.inspectMu.Lock()
+	defer s.inspectMu.Unlock()
+
+	if err == nil {
+		delete(s.inspectFailures, container.ID)
+		return status
+	}
+
+	s.inspectFailures[container.ID]++
+	if failures := s.inspectFailures[container.ID]; failures < s.config.UnknownAfterFailures {
+		log.Printf("[backend] Failed to inspect %s (%d/%d before marking unknown): %v",
+			container.ContainerName, failures, s.config.UnknownAfterFailures, err)
+		return container.Status
+	}
+	return "unknown"
+}
+
+// refreshContainerStatus updates the status from a fresh inspect, keeping the
+// stored one when the inspect fails; see statusAfterInspect.
+func (s *Server) refreshContainerStatus(ctx context.Context, container *models.Container, timeout time.Duration) {
+	inspectCtx, cancel := context.WithTimeout(ctx, timeout)
+	newStatus, err := s.containerStatus(inspectCtx, *container)
+	cancel()
+	if err == nil {
+		s.setContainerStatus(container, newStatus)
+	}
+}
+
 func (s *Server) HandleRemoveContainer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -433,11 +1295,23 @@ func (s *Server) HandleRemoveContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.db.RemoveContainer(id); err != nil {
+	if r.URL.Query().Get("keepLogs") == "true" {
+		if err := s.db.ArchiveContainer(id); err != nil {
+			log.Printf("[backend] Failed to archive container: %v", err)
+			s.jsonError(w, "Failed to remove container", http.StatusInternalServerError)
+			return
+		}
+		s.stopCollector(id)
+	} else if err := s.db.RemoveContainer(id); err != nil {
 		log.Printf("[backend] Failed to remove container: %v", err)
 		s.jsonError(w, "Failed to remove container", http.StatusInternalServerError)
 		return
 	}
+	s.inspectMu.Lock()
+	delete(s.inspectFailures, id)
+	s.inspectMu.Unlock()
+
+	s.hub.BroadcastToContainer("containers", websocket.NewContainerRemovedMessage(id))
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -462,7 +1336,71 @@ func (s *Server) HandleUpdateContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.db.UpdateContainer(id, req.ContainerName, req.Alias, req.ServerName, req.MaxPeriod, req.MaxLines); err != nil {
+	if req.ExpectedMaxGap != nil && *req.ExpectedMaxGap < 0 {
+		s.jsonError(w, "Expected max gap must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateMetadata(req.Metadata); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !validLogFormat(req.LogFormat) {
+		s.jsonError(w, "Unknown log format", http.StatusBadRequest)
+		return
+	}
+
+	if req.InitialLines != nil && *req.InitialLines < 0 {
+		s.jsonError(w, "initialLines must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.SampleRate != nil && *req.SampleRate < 1 {
+		s.jsonError(w, "sampleRate must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	if req.JSONTimestampFormat != nil && !validJSONTimestampFormat(*req.JSONTimestampFormat) {
+		s.jsonError(w, "Unknown JSON timestamp format", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := compileTransforms(req.Transforms); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.db.GetContainerByID(id)
+	if err != nil || existing == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	if req.LogFormat == models.LogFormatAuto {
+		req.LogFormat = models.LogFormatDocker
+		if existing.Source == models.SourceDocker {
+			req.LogFormat = s.resolveLogFormat(r.Context(), s.dockerFor(*existing), existing.ContainerID, models.LogFormatAuto)
+		}
+	}
+
+	collectStdout, collectStderr := existing.CollectStdout, existing.CollectStderr
+	if req.CollectStdout != nil {
+		collectStdout = *req.CollectStdout
+	}
+	if req.CollectStderr != nil {
+		collectStderr = *req.CollectStderr
+	}
+	if !collectStdout && !collectStderr {
+		s.jsonError(w, "At least one of stdout and stderr must be collected", http.StatusBadRequest)
+		return
+	}
+	if existing.Source == models.SourceKubernetes && (!collectStdout || !collectStderr) {
+		s.jsonError(w, "Kubernetes pods always collect both stdout and stderr", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateContainer(id, &req); err != nil {
 		log.Printf("[backend] Failed to update container: %v", err)
 		s.jsonError(w, "Failed to update container", http.StatusInternalServerError)
 		return
@@ -475,22 +1413,43 @@ func (s *Server) HandleUpdateContainer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	inspectCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	dockerContainer, err := s.docker.InspectContainer(inspectCtx, container.ContainerID)
+	newStatus, err := s.containerStatus(inspectCtx, *container)
 	cancel()
 	if err == nil {
-		newStatus := dockerContainer.State.Status
-		if container.Status != newStatus {
-			container.Status = newStatus
-			if err := s.db.UpdateContainerStatus(container.ID, newStatus); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
-			}
-		}
+		s.setContainerStatus(container, newStatus)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(container)
 }
 
+func (s *Server) HandleRenameServer(w http.ResponseWriter, r *http.Request) {
+	oldName := mux.Vars(r)["oldName"]
+
+	var req models.RenameServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	newName := strings.TrimSpace(req.Name)
+	if newName == "" {
+		s.jsonError(w, "New server name is required", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.db.RenameServer(oldName, newName)
+	if err != nil {
+		log.Printf("[backend] Failed to rename server %s: %v", oldName, err)
+		s.jsonError(w, "Failed to rename server", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[backend] Renamed server %s to %s on %d containers", oldName, newName, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RenameServerResponse{Updated: updated})
+}
+
 func (s *Server) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	containerID := vars["id"]
@@ -528,21 +1487,242 @@ func (s *Server) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logs, err := s.db.GetLogs(container.ID, limit, before)
+	var loc *time.Location
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			s.jsonError(w, "Invalid time zone", http.StatusBadRequest)
+			return
+		}
+	}
+
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		s.jsonError(w, "Invalid since time", http.StatusBadRequest)
+		return
+	}
+	until, err := parseTimeParam(r, "until")
+	if err != nil {
+		s.jsonError(w, "Invalid until time", http.StatusBadRequest)
+		return
+	}
+
+	query := db.LogQuery{
+		TrackedContainerID: container.ID,
+		Since:              since,
+		Until:              until,
+		PinnedOnly:         r.URL.Query().Get("pinnedOnly") == "true",
+		Contains:           r.URL.Query().Get("contains"),
+		Limit:              limit,
+	}
+	if levels := r.URL.Query().Get("level"); levels != "" {
+		for _, level := range strings.Split(levels, ",") {
+			level = strings.ToUpper(strings.TrimSpace(level))
+			if !db.ValidLogLevel(level) {
+				s.jsonError(w, "Invalid level", http.StatusBadRequest)
+				return
+			}
+			query.Levels = append(query.Levels, level)
+		}
+	}
+
+	// The total covers the whole filter while before only pages through it.
+	page := query
+	if before != nil && (page.Until == nil || before.Before(*page.Until)) {
+		page.Until = before
+	}
+	logs, err := s.db.GetLogs(page)
+	var total int
+	if err == nil {
+		total, err = s.db.CountLogs(query)
+	}
+	if err != nil {
+		log.Printf("[backend] Failed to get logs: %v", err)
+		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+		return
+	}
+
+	if loc != nil {
+		for i := range logs {
+			logs[i].LocalTime = time.Unix(0, logs[i].Timestamp).In(loc).Format(localTimeFormat)
+		}
+	}
+
+	logIDs := make([]string, len(logs))
+	for i := range logs {
+		logIDs[i] = logs[i].ID
+	}
+	annotations, err := s.db.GetAnnotations(logIDs)
+	if err != nil {
+		log.Printf("[backend] Failed to get annotations: %v", err)
+	}
+	for i := range logs {
+		logs[i].Annotations = annotations[logs[i].ID]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LogListResponse{
+		Logs:    logs,
+		HasMore: len(logs) == limit,
+		Total:   total,
+	})
+}
+
+func (s *Server) HandlePinLog(w http.ResponseWriter, r *http.Request) {
+	s.setLogPinned(w, r, true)
+}
+
+func (s *Server) HandleUnpinLog(w http.ResponseWriter, r *http.Request) {
+	s.setLogPinned(w, r, false)
+}
+
+func (s *Server) setLogPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	vars := mux.Vars(r)
+
+	found, err := s.db.SetLogPinned(vars["id"], vars["logId"], pinned)
+	if err != nil {
+		log.Printf("[backend] Failed to pin log: %v", err)
+		s.jsonError(w, "Failed to update log", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		s.jsonError(w, "Log not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": vars["logId"], "pinned": pinned})
+}
+
+func (s *Server) HandleAnnotateLog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req models.AnnotateLogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Note == "" {
+		s.jsonError(w, "Note is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Note) > maxAnnotationSize {
+		s.jsonError(w, "Note is too long", http.StatusBadRequest)
+		return
+	}
+
+	annotation, err := s.db.AddAnnotation(vars["id"], vars["logId"], req.Note, strings.TrimSpace(req.Author))
+	if err != nil {
+		log.Printf("[backend] Failed to annotate log: %v", err)
+		s.jsonError(w, "Failed to annotate log", http.StatusInternalServerError)
+		return
+	}
+	if annotation == nil {
+		s.jsonError(w, "Log not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(annotation)
+}
+
+func (s *Server) HandleGetLogContext(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	logID := r.URL.Query().Get("logId")
+	if logID == "" {
+		s.jsonError(w, "logId is required", http.StatusBadRequest)
+		return
+	}
+
+	before, err := parseCountParam(r, "before", 50)
+	if err != nil {
+		s.jsonError(w, "Invalid before count", http.StatusBadRequest)
+		return
+	}
+	after, err := parseCountParam(r, "after", 50)
+	if err != nil {
+		s.jsonError(w, "Invalid after count", http.StatusBadRequest)
+		return
+	}
+
+	logs, index, err := s.db.GetLogContext(container.ID, logID, before, after)
+	if err != nil {
+		log.Printf("[backend] Failed to get log context: %v", err)
+		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+		return
+	}
+	if logs == nil {
+		s.jsonError(w, "Log not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LogContextResponse{Logs: logs, Index: index})
+}
+
+func (s *Server) HandleGetLogLevels(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	container, err := s.db.GetContainerByID(containerID)
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	levels, err := s.db.GetDistinctLevels(container.ID)
+	if err != nil {
+		log.Printf("[backend] Failed to get log levels: %v", err)
+		s.jsonError(w, "Failed to get log levels", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LevelListResponse{Levels: levels})
+}
+
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseCountParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
 	if err != nil {
-		log.Printf("[backend] Failed to get logs: %v", err)
-		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
-		return
+		return 0, err
 	}
-
-	total, _ := s.db.GetLogCount(container.ID)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.LogListResponse{
-		Logs:    logs,
-		HasMore: len(logs) == limit,
-		Total:   total,
-	})
+	if n < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	return min(n, maxHistoryLimit), nil
 }
 
 var upgrader = ws.Upgrader{
@@ -553,6 +1733,15 @@ var upgrader = ws.Upgrader{
 	},
 }
 
+func (s *Server) acceptWSClient(w http.ResponseWriter) bool {
+	if s.config.MaxWSClients > 0 && s.hub.Count() >= s.config.MaxWSClients {
+		log.Printf("[websocket] Rejecting connection: %d clients connected", s.hub.Count())
+		s.jsonError(w, "Too many WebSocket clients", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
 func (s *Server) HandleStreamLogs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	containerID := vars["id"]
@@ -572,6 +1761,27 @@ func (s *Server) HandleStreamLogs(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, "Container not found", http.StatusNotFound)
 		return
 	}
+	if container.Source == models.SourceKubernetes && s.kube == nil {
+		s.jsonError(w, "Kubernetes source is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	var grep *grepWindow
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		lines := 0
+		if c := r.URL.Query().Get("context"); c != "" {
+			lines, err = strconv.Atoi(c)
+			if err != nil || lines < 0 || lines > maxGrepContext {
+				s.jsonError(w, fmt.Sprintf("context must be between 0 and %d", maxGrepContext), http.StatusBadRequest)
+				return
+			}
+		}
+		grep = newGrepWindow(filter, lines)
+	}
+
+	if !s.acceptWSClient(w) {
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -589,33 +1799,135 @@ func (s *Server) HandleStreamLogs(w http.ResponseWriter, r *http.Request) {
 	s.hub.Register(client)
 	go client.WritePump()
 	go client.ReadPump()
+	s.sendHello(client, container.ID)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
-	logsChan, err := s.docker.StreamContainerLogs(r.Context(), container.ContainerID, time.Time{})
+	logsChan, err := s.streamLogs(ctx, *container)
 	if err != nil {
 		log.Printf("[backend] Failed to stream logs: %v", err)
-		s.hub.SendToClient(client, websocket.NewErrorMessage("Failed to start log streaming"))
+		s.hub.SendToClient(client, websocket.NewContainerUnavailableMessage("Container is not available"))
+		s.hub.Unregister(client)
 		return
 	}
 
+	var repeats repeatCounter
+	transforms := containerTransforms(*container)
+	var lastLog atomic.Int64
+	lastLog.Store(time.Now().UnixNano())
+	var idle atomic.Bool
+	go s.watchStreamIdle(ctx, cancel, client, &lastLog, &idle)
+
+	// Like ingestLogs, record where the stored logs end so the collector
+	// resumes after them.
+	var lastTimestamp int64
+	defer func() {
+		if lastTimestamp > 0 {
+			if err := s.db.UpdateLastLogTimestamp(container.ID, lastTimestamp); err != nil {
+				log.Printf("[backend] Failed to update last log timestamp: %v", err)
+			}
+		}
+	}()
+
 	for logEntry := range logsChan {
 		entry := s.parseLogEntry(logEntry.Log, container.ContainerID, logEntry.Timestamp)
 		entry.TrackedContainerID = container.ID
+		entry.Message = transforms.apply(entry.Message)
 		if entry.Message == "" {
 			continue
 		}
-		s.hub.SendToClient(client, websocket.NewLogMessage(entry))
+		s.noteIngest(container.ID)
+		entry.Stream = logEntry.Stream
+		streamTs := entry.Timestamp
+		applyLogFormat(&entry, *container)
+		entry.Occurrence = repeats.occurrence(entry)
+		lastLog.Store(time.Now().UnixNano())
+		if grep == nil {
+			s.hub.SendToClient(client, websocket.NewLogMessage(entry))
+		} else {
+			for _, line := range grep.push(entry) {
+				s.hub.SendToClient(client, websocket.NewLogMessage(line))
+			}
+		}
 
-		if err := s.db.AddLog(r.Context(), &entry); err != nil {
+		if err := s.storeLog(r.Context(), &entry); err == nil || errors.Is(err, db.ErrDuplicateLog) {
+			lastTimestamp = max(lastTimestamp, streamTs)
+			if err == nil {
+				s.forwardLog(*container, entry)
+			}
+		} else if !errors.Is(err, errIngestionPaused) {
 			log.Printf("[backend] Failed to persist log: %v", err)
 		}
 
-		if container.MaxPeriod > 0 || container.MaxLines > 0 {
+		if container.InlineRetention && (container.MaxPeriod > 0 || container.MaxLines > 0) {
 			s.db.RetentionManager().ApplyRetentionForContainer(r.Context(), container.ID, container.MaxPeriod, container.MaxLines)
 		}
 	}
+
+	if !idle.Load() && s.hub.IsRegistered(client) {
+		s.hub.SendToClient(client, websocket.NewContainerUnavailableMessage("Container is not running"))
+		s.hub.Unregister(client)
+	}
+}
+
+// streamLogs opens a live stream of a container's logs from its Docker daemon
+// or, for Kubernetes sources, its pod.
+func (s *Server) streamLogs(ctx context.Context, container models.Container) (<-chan docker.LogMessage, error) {
+	opts := docker.StreamOptions{
+		NoStdout: !container.CollectStdout,
+		NoStderr: !container.CollectStderr,
+	}
+	if container.Source == models.SourceKubernetes {
+		return s.kube.StreamPodLogs(ctx, container.Namespace, container.Pod, container.PodContainer, opts)
+	}
+	return s.dockerFor(container).StreamContainerLogs(ctx, container.ContainerID, opts)
+}
+
+// watchStreamIdle stops a stream once its client has disconnected, or when
+// neither the client nor the container has sent anything for the configured
+// idle timeout, so abandoned tabs do not hold Docker follow streams open.
+func (s *Server) watchStreamIdle(ctx context.Context, cancel context.CancelFunc, client *websocket.Client, lastLog *atomic.Int64, idle *atomic.Bool) {
+	interval := 5 * time.Second
+	if timeout := s.config.StreamIdleTimeout; timeout > 0 && timeout/4 < interval {
+		interval = max(timeout/4, time.Second)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.hub.IsRegistered(client) {
+				cancel()
+				return
+			}
+
+			if s.config.StreamIdleTimeout <= 0 {
+				continue
+			}
+			lastActivity := max(started.UnixNano(), lastLog.Load(), client.LastRead().UnixNano())
+			if time.Since(time.Unix(0, lastActivity)) < s.config.StreamIdleTimeout {
+				continue
+			}
+
+			idle.Store(true)
+			s.hub.SendToClient(client, websocket.NewControlMessage("idle_timeout"))
+			s.hub.Unregister(client)
+			cancel()
+			return
+		}
+	}
 }
 
 func (s *Server) parseLogEntry(logLine, containerID string, timestamp time.Time) models.LogEntry {
+	if s.config.BinaryThreshold > 0 && nonPrintableRatio(logLine) > s.config.BinaryThreshold {
+		return models.LogEntry{}
+	}
+
 	message := strings.TrimSpace(logLine)
 
 	if len(message) >= 8 && message[0] == 1 {
@@ -645,6 +1957,27 @@ func (s *Server) parseLogEntry(logLine, containerID string, timestamp time.Time)
 	return entry
 }
 
+func nonPrintableRatio(line string) float64 {
+	if line == "" {
+		return 0
+	}
+
+	nonPrintable := 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			nonPrintable++
+		case r == '\t' || r == '\n' || r == '\r' || r == 0x1b:
+		case r < 32 || r == 127:
+			nonPrintable++
+		}
+		i += size
+	}
+
+	return float64(nonPrintable) / float64(len(line))
+}
+
 func stripANSIColors(s string) string {
 	ansi := regexp.MustCompile(`\x1b\[[0-9;]*m`)
 	s = ansi.ReplaceAllString(s, "")
@@ -670,10 +2003,28 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	limitStr := r.URL.Query().Get("limit")
 	limit := 100
+	if container.InitialLines > 0 {
+		limit = min(container.InitialLines, maxHistoryLimit)
+	}
 	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, maxHistoryLimit)
+		}
+	}
+
+	var since *time.Time
+	if maxAgeStr := r.URL.Query().Get("maxAge"); maxAgeStr != "" {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil || maxAge <= 0 {
+			s.jsonError(w, "maxAge must be a positive duration, e.g. 24h", http.StatusBadRequest)
+			return
 		}
+		t := time.Now().Add(-maxAge)
+		since = &t
+	}
+
+	if !s.acceptWSClient(w) {
+		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -688,23 +2039,120 @@ func (s *Server) HandleWS(w http.ResponseWriter, r *http.Request) {
 		Hub:         s.hub,
 		ContainerID: containerID,
 	}
+	s.enablePause(client, container.ID)
 
 	s.hub.Register(client)
 	go client.WritePump()
 	go client.ReadPump()
+	s.sendHello(client, container.ID)
+
+	if r.URL.Query().Get("history") == "false" {
+		return
+	}
 
-	logs, err := s.db.GetLogs(container.ID, limit, nil)
+	logs, err := s.db.GetLogs(db.LogQuery{TrackedContainerID: container.ID, Since: since, Limit: limit})
 	if err != nil {
 		log.Printf("[backend] Failed to get existing logs: %v", err)
-	} else {
-		s.hub.SendToClient(client, websocket.NewLogsBatchMessage(logs))
+		return
+	}
+	s.sendLogsBatch(client, logs)
+	// Older logs may still exist; hello's lastLogTimestamp says how old.
+	if len(logs) == 0 && since != nil {
+		s.hub.SendToClient(client, websocket.NewStatusMessage("no_recent_logs"))
 	}
 }
 
-func (s *Server) HandleWSContainers(w http.ResponseWriter, r *http.Request) {
+// sendHello tells a new client which server run it is talking to and how far
+// the container's stored logs reach.
+func (s *Server) sendHello(client *websocket.Client, trackedContainerID string) {
+	lastTs, err := s.db.GetNewestLogTimestamp(trackedContainerID)
+	if err != nil {
+		log.Printf("[backend] Failed to get newest log timestamp: %v", err)
+	}
+	s.hub.SendToClient(client, websocket.NewHelloMessage(s.epoch, lastTs))
+}
+
+// sendLogsBatch sends history in chunks of at most historyChunkSize lines and
+// historyChunkBytes bytes, or the WebSocket message limit if that is lower,
+// so long lines are not truncated to fit a chunk. The first chunk replaces
+// what the client shows and the rest append to it.
+func (s *Server) sendLogsBatch(client *websocket.Client, logs []models.LogEntry) {
+	if len(logs) == 0 {
+		batch := websocket.NewLogsBatchMessage(logs)
+		batch.Replace = true
+		s.hub.SendToClient(client, batch)
+		return
+	}
+
+	budget := historyChunkBytes
+	if s.config.WSMaxMessageSize > 0 {
+		envelope := websocket.NewLogsBatchMessage([]models.LogEntry{})
+		envelope.Replace = true
+		data, _ := json.Marshal(envelope)
+		budget = min(budget, s.config.WSMaxMessageSize-len(data))
+	}
+
+	start, size := 0, 0
+	for i, entry := range logs {
+		data, _ := json.Marshal(entry)
+		// The +1 is the comma between entries.
+		if i > start && (i-start == historyChunkSize || size+len(data)+1 > budget) {
+			s.sendLogsChunk(client, logs[start:i], start == 0)
+			start, size = i, 0
+		}
+		size += len(data) + 1
+	}
+	s.sendLogsChunk(client, logs[start:], start == 0)
+}
+
+func (s *Server) sendLogsChunk(client *websocket.Client, logs []models.LogEntry, replace bool) {
+	batch := websocket.NewLogsBatchMessage(logs)
+	batch.Replace = replace
+	s.hub.SendToClient(client, batch)
+}
+
+func (s *Server) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	container, err := s.db.GetContainerByID(containerID)
+	if err != nil || container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	speed := 1.0
+	if speedStr := r.URL.Query().Get("speed"); speedStr != "" {
+		v, err := strconv.ParseFloat(speedStr, 64)
+		if err != nil || v <= 0 {
+			s.jsonError(w, "Speed must be a positive number", http.StatusBadRequest)
+			return
+		}
+		speed = v
+	}
+
+	limit := 1000
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, maxHistoryLimit)
+		}
+	}
+
+	logs, err := s.db.GetLogs(db.LogQuery{TrackedContainerID: container.ID, Limit: limit})
+	if err != nil {
+		log.Printf("[backend] Failed to get logs for replay: %v", err)
+		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(logs)
+
+	if !s.acceptWSClient(w) {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[websocket] Failed to upgrade containers: %v", err)
+		log.Printf("[websocket] Failed to upgrade replay: %v", err)
 		return
 	}
 
@@ -712,16 +2160,52 @@ func (s *Server) HandleWSContainers(w http.ResponseWriter, r *http.Request) {
 		Conn:        conn,
 		Send:        make(chan []byte, 256),
 		Hub:         s.hub,
-		ContainerID: "containers",
+		ContainerID: "replay:" + containerID,
 	}
 
 	s.hub.Register(client)
 	go client.WritePump()
+	go client.ReadPump()
+	s.sendHello(client, container.ID)
+
+	for i, entry := range logs {
+		if i > 0 {
+			delay := time.Duration(float64(entry.Timestamp-logs[i-1].Timestamp) / speed)
+			time.Sleep(min(max(delay, 0), maxReplayDelay))
+			if !s.hub.IsRegistered(client) {
+				return
+			}
+		}
+		s.hub.SendToClient(client, websocket.NewLogMessage(entry))
+	}
 
-	go func() {
-		time.Sleep(200 * time.Millisecond)
-		s.sendContainersUpdate(client)
-	}()
+	s.hub.SendToClient(client, websocket.NewControlMessage("replay_complete"))
+}
+
+func (s *Server) HandleWSContainers(w http.ResponseWriter, r *http.Request) {
+	if !s.acceptWSClient(w) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[websocket] Failed to upgrade containers: %v", err)
+		return
+	}
+
+	client := &websocket.Client{
+		Conn:            conn,
+		Send:            make(chan []byte, 256),
+		Hub:             s.hub,
+		ContainerID:     "containers",
+		DeltaContainers: r.URL.Query().Get("mode") == "delta",
+	}
+
+	s.hub.Register(client)
+	go client.WritePump()
+	s.hub.SendToClient(client, websocket.NewHelloMessage(s.epoch, 0))
+
+	s.sendContainersUpdate(client)
 }
 
 func (s *Server) sendContainersUpdate(client *websocket.Client) {
@@ -734,34 +2218,161 @@ func (s *Server) sendContainersUpdate(client *websocket.Client) {
 	}
 
 	for i := range containers {
-		container := &containers[i]
-		inspectCtx, inspectCancel := context.WithTimeout(ctx, 1*time.Second)
-		dockerContainer, err := s.docker.InspectContainer(inspectCtx, container.ContainerID)
-		inspectCancel()
-		if err != nil {
-			container.Status = "unknown"
-			if err := s.db.UpdateContainerStatus(container.ID, "unknown"); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
-			}
-			continue
+		s.refreshContainerStatus(ctx, &containers[i], time.Second)
+	}
+
+	s.hub.SendContainers(client, containers)
+}
+
+func (s *Server) HandleWSClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":      s.hub.Count(),
+		"containers": s.hub.Snapshot(),
+	})
+}
+
+func (s *Server) HandlePauseRetention(w http.ResponseWriter, r *http.Request) {
+	s.db.RetentionManager().Pause()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"paused": true})
+}
+
+func (s *Server) HandleApplyRetention(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	retention := s.db.RetentionManager()
+	if retention.Paused() {
+		s.jsonError(w, "Retention is paused", http.StatusConflict)
+		return
+	}
+
+	removed, err := retention.ApplyRetentionForContainer(r.Context(), container.ID, container.MaxPeriod, container.MaxLines)
+	if err != nil {
+		log.Printf("[backend] Failed to apply retention for %s: %v", container.ContainerName, err)
+		s.jsonError(w, "Failed to apply retention", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"removed": removed})
+}
+
+// HandlePreviewRetention reports what the given limits, or the container's
+// own where a limit is not passed, would delete.
+func (s *Server) HandlePreviewRetention(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	maxPeriod, maxLines := container.MaxPeriod, container.MaxLines
+	if v := r.URL.Query().Get("maxPeriod"); v != "" {
+		maxPeriod, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || maxPeriod < 0 {
+			s.jsonError(w, "Invalid maxPeriod", http.StatusBadRequest)
+			return
 		}
-		newStatus := dockerContainer.State.Status
-		if container.Status != newStatus {
-			container.Status = newStatus
-			if err := s.db.UpdateContainerStatus(container.ID, newStatus); err != nil {
-				log.Printf("[backend] Failed to update container status: %v", err)
-			}
+	}
+	if v := r.URL.Query().Get("maxLines"); v != "" {
+		maxLines, err = strconv.Atoi(v)
+		if err != nil || maxLines < 0 {
+			s.jsonError(w, "Invalid maxLines", http.StatusBadRequest)
+			return
 		}
 	}
 
-	msg := websocket.NewContainersMessage(containers)
-	s.hub.SendToClient(client, msg)
+	preview, err := s.db.RetentionManager().PreviewRetention(r.Context(), container.ID, maxPeriod, maxLines)
+	if err != nil {
+		log.Printf("[backend] Failed to preview retention for %s: %v", container.ContainerName, err)
+		s.jsonError(w, "Failed to preview retention", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+func (s *Server) HandleResumeRetention(w http.ResponseWriter, r *http.Request) {
+	s.db.RetentionManager().Resume()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"paused": false})
 }
 
 func (s *Server) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	status := map[string]interface{}{
 		"status":    "healthy",
 		"timestamp": time.Now().Unix(),
+		"wsClients": s.hub.Count(),
+		"readOnly":  s.config.ReadOnly,
+		"retention": map[string]interface{}{
+			"paused": s.db.RetentionManager().Paused(),
+		},
+	}
+
+	status["streams"] = map[string]interface{}{
+		"following": s.followingStreams(),
+		"max":       s.config.MaxStreams,
+	}
+
+	diskFull := s.diskFull.Load()
+	ingestion := map[string]interface{}{
+		"paused": diskFull,
+	}
+	if diskFull {
+		ingestion["reason"] = "disk_full"
+		ingestion["since"] = s.diskFullSince.Load()
+		status["status"] = "degraded"
+	}
+	status["ingestion"] = ingestion
+
+	queued, dropped := s.hub.QueueStats()
+	status["broadcast"] = map[string]interface{}{
+		"queued":  queued,
+		"dropped": dropped,
+	}
+
+	dbStats := s.db.Stats()
+	status["db"] = map[string]interface{}{
+		"openConnections":   dbStats.OpenConnections,
+		"inUse":             dbStats.InUse,
+		"idle":              dbStats.Idle,
+		"waitCount":         dbStats.WaitCount,
+		"waitDurationMs":    dbStats.WaitDuration.Milliseconds(),
+		"maxIdleClosed":     dbStats.MaxIdleClosed,
+		"maxLifetimeClosed": dbStats.MaxLifetimeClosed,
+	}
+
+	lastStatusRun := time.Unix(0, s.lastStatusRun.Load())
+	lastCollectionRun := time.Unix(0, s.lastCollectionRun.Load())
+	collectionAlive := time.Since(lastStatusRun) <= 2*statusInterval && time.Since(lastCollectionRun) <= 2*collectionInterval
+	status["collection"] = map[string]interface{}{
+		"alive":             collectionAlive,
+		"lastStatusRun":     lastStatusRun.Unix(),
+		"lastCollectionRun": lastCollectionRun.Unix(),
+	}
+	if !collectionAlive {
+		status["status"] = "degraded"
+	}
+
+	status["containerList"] = map[string]interface{}{
+		"inspectWorkers": s.config.ListInspectWorkers,
+		"lastDurationMs": time.Duration(s.lastListDuration.Load()).Milliseconds(),
 	}
 
 	if err := s.docker.PingDocker(r.Context()); err != nil {
@@ -786,3 +2397,53 @@ func (s *Server) HandleDockerContainers(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(containers)
 }
+
+// containerNotFound answers a failed name lookup with the containers whose
+// names come closest, since most misses are typos or a wrong case.
+func (s *Server) containerNotFound(w http.ResponseWriter, r *http.Request, dc dockerAPI, req *models.AddContainerRequest) {
+	if req.By == "image" {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	suggestions, err := dc.SuggestContainers(r.Context(), req.Name, maxNameSuggestions)
+	if err != nil || len(suggestions) == 0 {
+		s.jsonError(w, fmt.Sprintf("No container named %q", req.Name), http.StatusNotFound)
+		return
+	}
+
+	names := make([]string, 0, len(suggestions))
+	for _, c := range suggestions {
+		names = append(names, c.Name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       fmt.Sprintf("No container named %q. Did you mean: %s?", req.Name, strings.Join(names, ", ")),
+		"suggestions": suggestions,
+	})
+}
+
+func (s *Server) HandleResolveContainer(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		s.jsonError(w, "Container name is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.docker.ResolveContainer(r.Context(), name)
+	if err != nil {
+		log.Printf("[backend] Failed to resolve container: %v", err)
+		s.jsonError(w, "Failed to resolve container", http.StatusInternalServerError)
+		return
+	}
+
+	if result == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/docker"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/mux"
+	ws "github.com/gorilla/websocket"
+)
+
+// fakeDocker stands in for a daemon. Containers are looked up by name in
+// containers; stream and inspect, when set, answer StreamContainerLogs and
+// InspectContainer.
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers map[string]string
+	findErr    error
+	stream     func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error)
+	inspect    func(containerID string) (*types.ContainerJSON, error)
+
+	streamCalls map[string]int
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		containers:  make(map[string]string),
+		streamCalls: make(map[string]int),
+	}
+}
+
+func (f *fakeDocker) streams(containerID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.streamCalls[containerID]
+}
+
+func (f *fakeDocker) ListContainers(ctx context.Context) ([]types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []types.Container
+	for name, id := range f.containers {
+		list = append(list, types.Container{ID: id, Names: []string{"/" + name}, State: "running"})
+	}
+	return list, nil
+}
+
+func (f *fakeDocker) ListContainersInfo(ctx context.Context) ([]docker.ContainerInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeDocker) FindContainerByName(ctx context.Context, name string) (*types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.findErr != nil {
+		return nil, f.findErr
+	}
+	id, ok := f.containers[name]
+	if !ok {
+		return nil, nil
+	}
+	return &types.Container{ID: id, Names: []string{"/" + name}, State: "running"}, nil
+}
+
+func (f *fakeDocker) FindContainerByImage(ctx context.Context, image string) (*types.Container, []docker.ContainerInfo, error) {
+	return nil, nil, nil
+}
+
+func (f *fakeDocker) SuggestContainers(ctx context.Context, name string, limit int) ([]docker.ContainerInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeDocker) ResolveContainer(ctx context.Context, name string) (*docker.ResolveResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeDocker) InspectContainer(ctx context.Context, containerID string) (*types.ContainerJSON, error) {
+	if f.inspect != nil {
+		return f.inspect(containerID)
+	}
+	return &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		State: &types.ContainerState{Status: "running"},
+	}}, nil
+}
+
+func (f *fakeDocker) StreamContainerLogs(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+	f.mu.Lock()
+	f.streamCalls[containerID]++
+	stream := f.stream
+	f.mu.Unlock()
+
+	if stream == nil {
+		ch := make(chan docker.LogMessage)
+		close(ch)
+		return ch, nil
+	}
+	return stream(ctx, containerID, opts)
+}
+
+func (f *fakeDocker) OldestLogTimestamp(ctx context.Context, containerID string) (time.Time, error) {
+	return time.Time{}, errors.New("not supported")
+}
+
+func (f *fakeDocker) PingDocker(ctx context.Context) error { return nil }
+
+func (f *fakeDocker) DaemonHost() string { return "unix:///fake.sock" }
+
+func newTestServer(t testing.TB, cfg Config) (*Server, *fakeDocker) {
+	t.Helper()
+	database, err := db.NewSQLiteDB(filepath.Join(t.TempDir(), "logs.db"), db.Options{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	s := NewServer(database, nil, cfg)
+	fake := newFakeDocker()
+	s.docker = fake
+	return s, fake
+}
+
+// dockerID makes a 64 character container ID, as Docker uses.
+func dockerID(name string) string {
+	return (name + strings.Repeat("0", 64))[:64]
+}
+
+func addTestContainer(t testing.TB, s *Server, fake *fakeDocker, name string) models.Container {
+	t.Helper()
+	id := dockerID(name)
+	fake.mu.Lock()
+	fake.containers[name] = id
+	fake.mu.Unlock()
+
+	container, err := s.db.AddContainer(&models.AddContainerRequest{Name: name}, id, name, "test")
+	if err != nil {
+		t.Fatalf("add container: %v", err)
+	}
+	return *container
+}
+
+// lineStream returns a closed stream of messages, one per line, a millisecond
+// apart.
+func lineStream(start time.Time, lines ...string) <-chan docker.LogMessage {
+	ch := make(chan docker.LogMessage, len(lines))
+	for i, line := range lines {
+		ch <- docker.LogMessage{Log: line, Timestamp: start.Add(time.Duration(i) * time.Millisecond), Stream: "stdout"}
+	}
+	close(ch)
+	return ch
+}
+
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func storedMessages(t testing.TB, s *Server, trackedContainerID string) []string {
+	t.Helper()
+	logs, err := s.db.GetLogs(db.LogQuery{TrackedContainerID: trackedContainerID, Limit: 1000})
+	if err != nil {
+		t.Fatalf("get logs: %v", err)
+	}
+	messages := make([]string, len(logs))
+	for i, entry := range logs {
+		messages[len(logs)-1-i] = entry.Message
+	}
+	return messages
+}
+
+// dialWS serves handler at pattern and opens a WebSocket to path.
+func dialWS(t testing.TB, pattern string, handler http.HandlerFunc, path string) *ws.Conn {
+	t.Helper()
+	router := mux.NewRouter()
+	router.HandleFunc(pattern, handler)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// wsMessage is any message the server sends on a WebSocket.
+type wsMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// readWS reads messages until one of type want arrives.
+func readWS(t testing.TB, conn *ws.Conn, want string) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for a %s message: %v", want, err)
+		}
+		if msg.Type == want {
+			return msg
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	ws "github.com/gorilla/websocket"
+)
+
+func addHistory(t *testing.T, s *Server, container models.Container, messages func(i int) string, n int) {
+	t.Helper()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < n; i++ {
+		entry := &models.LogEntry{TrackedContainerID: container.ID, ContainerID: container.ContainerID,
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond).UnixNano(), Message: messages(i)}
+		if err := s.db.AddLog(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readHistory reads the logs_batch messages holding total lines and returns
+// them, checking that only the first replaces what the client shows.
+func readHistory(t *testing.T, conn *ws.Conn, total int) [][]models.LogEntry {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var batches [][]models.LogEntry
+	received := 0
+	for received < total {
+		var msg struct {
+			Type    string            `json:"type"`
+			Payload []models.LogEntry `json:"payload"`
+			Replace bool              `json:"replace"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for history after %d batches: %v", len(batches), err)
+		}
+		if msg.Type != "logs_batch" {
+			continue
+		}
+		if msg.Replace != (len(batches) == 0) {
+			t.Fatalf("batch %d has replace %t, want only the first to replace", len(batches), msg.Replace)
+		}
+		batches = append(batches, msg.Payload)
+		received += len(msg.Payload)
+	}
+	return batches
+}
+
+func TestLargeHistoryIsChunked(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	container := addTestContainer(t, s, fake, "api")
+	addHistory(t, s, container, func(i int) string { return fmt.Sprintf("line %d", i) }, maxHistoryLimit+100)
+
+	// The limit is clamped to maxHistoryLimit.
+	conn := dialWS(t, "/api/ws/{id}", s.HandleWS, "/api/ws/"+container.ID+"?limit=100000")
+	batches := readHistory(t, conn, maxHistoryLimit)
+
+	received := 0
+	for i, batch := range batches {
+		if len(batch) != historyChunkSize {
+			t.Fatalf("batch %d has %d lines, want %d", i, len(batch), historyChunkSize)
+		}
+		received += len(batch)
+	}
+	if received != maxHistoryLimit {
+		t.Fatalf("received %d lines, want the limit clamped to %d", received, maxHistoryLimit)
+	}
+	if newest := batches[0][0].Message; newest != fmt.Sprintf("line %d", maxHistoryLimit+99) {
+		t.Fatalf("first line = %q, want the newest", newest)
+	}
+}
+
+func TestLongLinesAreChunkedByBytes(t *testing.T) {
+	for _, maxMessageSize := range []int{0, 64 * 1024} {
+		t.Run(fmt.Sprintf("max=%d", maxMessageSize), func(t *testing.T) {
+			s, fake := newTestServer(t, Config{WSMaxMessageSize: maxMessageSize})
+			go s.hub.Run()
+			container := addTestContainer(t, s, fake, "api")
+			line := strings.Repeat("x", 30*1024)
+			addHistory(t, s, container, func(int) string { return line }, 20)
+
+			conn := dialWS(t, "/api/ws/{id}", s.HandleWS, "/api/ws/"+container.ID+"?limit=20")
+			batches := readHistory(t, conn, 20)
+
+			budget := historyChunkBytes
+			if maxMessageSize > 0 {
+				budget = maxMessageSize
+			}
+			for i, batch := range batches {
+				if size := len(batch) * len(line); size > budget {
+					t.Fatalf("batch %d holds %d bytes of messages, over %d", i, size, budget)
+				}
+				for _, entry := range batch {
+					if entry.Truncated || entry.Message != line {
+						t.Fatalf("batch %d has a truncated line", i)
+					}
+				}
+			}
+			if len(batches) < 2 {
+				t.Fatalf("got %d batches, want the lines split by size", len(batches))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"regexp"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// syslogHeader matches the "Jan  2 15:04:05 host ident[pid]: " prefix that
+// journalctl's short output and syslog forwarding put in front of a message.
+var syslogHeader = regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2} \S+ ([^\s\[:]+)(?:\[(\d+)\])?: `)
+
+// journaldLevels maps syslog priorities (0 emerg .. 7 debug) to the levels
+// db.DetectLevel uses.
+var journaldLevels = [8]string{"ERROR", "ERROR", "ERROR", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// parseJournaldLine strips a "<N>" priority marker and a syslog header from a
+// line read back from the journald driver. The priority becomes the entry's
+// level, and the identifier and PID are kept in details under their journal
+// field names.
+func parseJournaldLine(entry *models.LogEntry) {
+	message := entry.Message
+
+	if len(message) >= 3 && message[0] == '<' && message[2] == '>' && message[1] >= '0' && message[1] <= '7' {
+		entry.Level = journaldLevels[message[1]-'0']
+		message = message[3:]
+	}
+
+	if m := syslogHeader.FindStringSubmatchIndex(message); m != nil {
+		if entry.Details == nil {
+			entry.Details = make(map[string]string)
+		}
+		entry.Details["SYSLOG_IDENTIFIER"] = message[m[2]:m[3]]
+		if m[4] >= 0 {
+			entry.Details["_PID"] = message[m[4]:m[5]]
+		}
+		message = message[m[1]:]
+	}
+
+	if message != "" {
+		entry.Message = message
+	}
+}
+
+// resolveLogFormat turns "auto" (or an unset format) into the format matching
+// the container's Docker logging driver.
+func (s *Server) resolveLogFormat(ctx context.Context, dc dockerAPI, containerID, requested string) string {
+	if requested != "" && requested != models.LogFormatAuto {
+		return requested
+	}
+
+	info, err := dc.InspectContainer(ctx, containerID)
+	if err != nil {
+		log.Printf("[backend] Failed to detect log driver of %s, assuming docker format: %v", containerID, err)
+		return models.LogFormatDocker
+	}
+	if info.ContainerJSONBase != nil && info.HostConfig != nil && info.HostConfig.LogConfig.Type == "journald" {
+		return models.LogFormatJournald
+	}
+	return models.LogFormatDocker
+}
+
+func validLogFormat(format string) bool {
+	switch format {
+	case "", models.LogFormatAuto, models.LogFormatDocker, models.LogFormatJournald, models.LogFormatJSON:
+		return true
+	}
+	return false
+}
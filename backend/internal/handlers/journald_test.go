@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestParseJournaldLine(t *testing.T) {
+	for _, tc := range []struct {
+		line    string
+		message string
+		level   string
+		details map[string]string
+	}{
+		{
+			line:    "<3>Jan  2 15:04:05 web-1 nginx[1234]: upstream timed out",
+			message: "upstream timed out",
+			level:   "ERROR",
+			details: map[string]string{"SYSLOG_IDENTIFIER": "nginx", "_PID": "1234"},
+		},
+		{
+			line:    "<4>Oct 15 08:30:00 db-host postgres: checkpoints are occurring too frequently",
+			message: "checkpoints are occurring too frequently",
+			level:   "WARN",
+			details: map[string]string{"SYSLOG_IDENTIFIER": "postgres"},
+		},
+		{
+			line:    "<7>cache miss for key user:42",
+			message: "cache miss for key user:42",
+			level:   "DEBUG",
+		},
+		{
+			line:    "Jan 12 01:02:03 host app[7]: listening on :8080",
+			message: "listening on :8080",
+			details: map[string]string{"SYSLOG_IDENTIFIER": "app", "_PID": "7"},
+		},
+		{
+			// Not a priority marker or a header; left alone.
+			line:    "<html> returned for /api/health",
+			message: "<html> returned for /api/health",
+		},
+	} {
+		entry := models.LogEntry{Message: tc.line}
+		parseJournaldLine(&entry)
+		if entry.Message != tc.message || entry.Level != tc.level {
+			t.Errorf("%q: message %q, level %q, want %q, %q", tc.line, entry.Message, entry.Level, tc.message, tc.level)
+		}
+		if len(entry.Details) != len(tc.details) {
+			t.Errorf("%q: details %v, want %v", tc.line, entry.Details, tc.details)
+			continue
+		}
+		for key, value := range tc.details {
+			if entry.Details[key] != value {
+				t.Errorf("%q: details %v, want %v", tc.line, entry.Details, tc.details)
+			}
+		}
+	}
+}
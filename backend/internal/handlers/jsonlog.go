@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+var (
+	defaultJSONTimestampFields = []string{"time", "ts", "timestamp", "@timestamp"}
+	defaultJSONMessageFields   = []string{"msg", "message"}
+	defaultJSONLevelFields     = []string{"level", "severity", "lvl"}
+)
+
+func applyLogFormat(entry *models.LogEntry, container models.Container) {
+	switch container.LogFormat {
+	case models.LogFormatJournald:
+		parseJournaldLine(entry)
+	case models.LogFormatJSON:
+		parseJSONLine(entry, container.JSONFields)
+	}
+	if entry.Level == "" {
+		entry.Level = db.DetectLevel(entry.Message)
+	}
+}
+
+// parseJSONLine takes the message, level and timestamp of a JSON log line from
+// the configured fields and keeps the other fields in entry.Fields. The level
+// and timestamp fields stay there as written, so logs can be aggregated by
+// them; the message field is dropped as it would store the text twice. Lines
+// that are not JSON objects, and fields that are missing or do not parse, keep
+// what the Docker line already provided.
+func parseJSONLine(entry *models.LogEntry, fields models.JSONFields) {
+	if !strings.HasPrefix(entry.Message, "{") {
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(entry.Message))
+	decoder.UseNumber()
+	var obj map[string]interface{}
+	if err := decoder.Decode(&obj); err != nil {
+		return
+	}
+
+	if _, v, ok := jsonField(obj, fields.JSONTimestampField, defaultJSONTimestampFields); ok {
+		if ts, ok := parseJSONTimestamp(v, fields.JSONTimestampFormat); ok {
+			entry.Timestamp = ts.UnixNano()
+		}
+	}
+
+	if _, v, ok := jsonField(obj, fields.JSONLevelField, defaultJSONLevelFields); ok {
+		if level := jsonLevel(v); level != "" {
+			entry.Level = level
+		}
+	}
+
+	if name, v, ok := jsonField(obj, fields.JSONMessageField, defaultJSONMessageFields); ok {
+		if message, ok := v.(string); ok && message != "" {
+			entry.Message = message
+			delete(obj, name)
+		}
+	}
+
+	if len(obj) > 0 {
+		entry.Fields = obj
+	}
+}
+
+// jsonField looks up the configured field, or the first default present when
+// none is configured, and returns the name it was found under.
+func jsonField(obj map[string]interface{}, field string, defaults []string) (string, interface{}, bool) {
+	if field != "" {
+		v, ok := obj[field]
+		return field, v, ok
+	}
+	for _, name := range defaults {
+		if v, ok := obj[name]; ok {
+			return name, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// parseJSONTimestamp reads a timestamp in format: rfc3339, unix, unix_ms,
+// unix_us, unix_ns or a Go time layout. Without a format, strings are read as
+// RFC3339 and numbers as Unix time in whichever unit fits their magnitude.
+func parseJSONTimestamp(v interface{}, format string) (time.Time, bool) {
+	switch format {
+	case "", "rfc3339":
+		if s, ok := v.(string); ok {
+			ts, err := time.Parse(time.RFC3339Nano, s)
+			return ts, err == nil
+		}
+		if format == "" {
+			return parseUnixTimestamp(v, "")
+		}
+		return time.Time{}, false
+	case "unix", "unix_ms", "unix_us", "unix_ns":
+		return parseUnixTimestamp(v, format)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(format, s)
+	return ts, err == nil
+}
+
+func parseUnixTimestamp(v interface{}, unit string) (time.Time, bool) {
+	var n json.Number
+	switch v := v.(type) {
+	case json.Number:
+		n = v
+	case string:
+		n = json.Number(v)
+	default:
+		return time.Time{}, false
+	}
+
+	f, err := n.Float64()
+	if err != nil || f <= 0 {
+		return time.Time{}, false
+	}
+
+	if unit == "" {
+		switch {
+		case f < 1e11:
+			unit = "unix"
+		case f < 1e14:
+			unit = "unix_ms"
+		case f < 1e17:
+			unit = "unix_us"
+		default:
+			unit = "unix_ns"
+		}
+	}
+
+	var digits int
+	switch unit {
+	case "unix":
+		digits = 9
+	case "unix_ms":
+		digits = 6
+	case "unix_us":
+		digits = 3
+	}
+	if digits > 0 {
+		if ns, ok := scaleDecimal(n.String(), digits); ok {
+			return time.Unix(0, ns), true
+		}
+		// Exponent forms: scale the whole and fractional parts apart so
+		// the fraction keeps what precision the float has.
+		scale := math.Pow10(digits)
+		whole, frac := math.Modf(f)
+		return time.Unix(0, int64(whole)*int64(scale)+int64(math.Round(frac*scale))), true
+	}
+	// Nanosecond values need all their digits, which a float64 does not keep.
+	ns, err := strconv.ParseInt(n.String(), 10, 64)
+	if err != nil {
+		return time.Unix(0, int64(f)), true
+	}
+	return time.Unix(0, ns), true
+}
+
+// scaleDecimal multiplies a plain decimal such as "1700000000.123456789" by
+// 10^digits without going through a float64, which cannot hold all the
+// digits of a nanosecond timestamp. Digits beyond nanoseconds are dropped.
+func scaleDecimal(s string, digits int) (int64, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if len(frac) > digits {
+		frac = frac[:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	f, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return w*int64(math.Pow10(digits)) + f, true
+}
+
+// jsonLevel maps level names and pino/bunyan numeric levels to the levels
+// db.DetectLevel uses.
+func jsonLevel(v interface{}) string {
+	if n, ok := v.(json.Number); ok {
+		level, err := n.Int64()
+		if err != nil {
+			return ""
+		}
+		switch {
+		case level >= 50:
+			return "ERROR"
+		case level >= 40:
+			return "WARN"
+		case level >= 30:
+			return "INFO"
+		default:
+			return "DEBUG"
+		}
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return ""
+	}
+	switch strings.ToLower(s) {
+	case "error", "err", "fatal", "critical", "crit", "panic", "alert", "emerg", "emergency":
+		return "ERROR"
+	case "warn", "warning":
+		return "WARN"
+	case "info", "notice", "information":
+		return "INFO"
+	case "debug", "trace", "verbose":
+		return "DEBUG"
+	}
+	return ""
+}
+
+// validJSONTimestampFormat accepts the named formats and Go layouts, which
+// always contain a reference year or hour.
+func validJSONTimestampFormat(format string) bool {
+	switch format {
+	case "", "rfc3339", "unix", "unix_ms", "unix_us", "unix_ns":
+		return true
+	}
+	return strings.Contains(format, "2006") || strings.Contains(format, "15")
+}
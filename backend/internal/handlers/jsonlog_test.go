@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestParseJSONTimestamp(t *testing.T) {
+	sec := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		value  interface{}
+		format string
+		want   time.Time
+		ok     bool
+	}{
+		// Without a format the unit follows the magnitude.
+		{"seconds", json.Number("1700000000"), "", sec, true},
+		{"milliseconds", json.Number("1700000000123"), "", sec.Add(123 * time.Millisecond), true},
+		{"microseconds", json.Number("1700000000123456"), "", sec.Add(123456 * time.Microsecond), true},
+		{"nanoseconds", json.Number("1700000000123456789"), "", sec.Add(123456789), true},
+		{"numeric string", "1700000000", "", time.Time{}, false},
+		{"numeric string with a unit", "1700000000", "unix", sec, true},
+		{"rfc3339 string", "2023-11-14T22:13:20.5Z", "", sec.Add(500 * time.Millisecond), true},
+		{"zero", json.Number("0"), "", time.Time{}, false},
+		{"not a number", "yesterday", "", time.Time{}, false},
+
+		// Whole and fractional parts are scaled apart.
+		{"fractional seconds", json.Number("1700000000.123456789"), "", sec.Add(123456789), true},
+		{"fractional milliseconds", json.Number("1700000000123.5"), "", sec.Add(123*time.Millisecond + 500*time.Microsecond), true},
+
+		// A named unit overrides the magnitude.
+		{"unix_ms", json.Number("1500"), "unix_ms", time.Unix(1, 500_000_000), true},
+		{"unix_us", json.Number("1700000000"), "unix_us", time.Unix(1700, 0), true},
+		{"unix_ns", json.Number("1700000000123456789"), "unix_ns", sec.Add(123456789), true},
+		{"exponent", json.Number("1.7e9"), "unix", sec, true},
+		{"rfc3339 rejects numbers", json.Number("1700000000"), "rfc3339", time.Time{}, false},
+
+		// Go layouts read strings only.
+		{"go layout", "14/11/2023 22:13:20", "02/01/2006 15:04:05", sec, true},
+		{"go layout with zone", "2023-11-14 22:13:20.250 +0000", "2006-01-02 15:04:05.000 -0700", sec.Add(250 * time.Millisecond), true},
+		{"go layout mismatch", "2023-11-14", "02/01/2006 15:04:05", time.Time{}, false},
+		{"go layout number", json.Number("1700000000"), "02/01/2006 15:04:05", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseJSONTimestamp(tt.value, tt.format)
+			if ok != tt.ok {
+				t.Fatalf("ok = %t, want %t", ok, tt.ok)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Fatalf("got %s, want %s", got.UTC().Format(time.RFC3339Nano), tt.want.UTC().Format(time.RFC3339Nano))
+			}
+		})
+	}
+}
+
+func TestJSONLevel(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		// pino/bunyan numeric levels.
+		{json.Number("10"), "DEBUG"},
+		{json.Number("20"), "DEBUG"},
+		{json.Number("30"), "INFO"},
+		{json.Number("40"), "WARN"},
+		{json.Number("50"), "ERROR"},
+		{json.Number("60"), "ERROR"},
+		{json.Number("35.5"), ""},
+		{"Warning", "WARN"},
+		{"fatal", "ERROR"},
+		{"notice", "INFO"},
+		{"trace", "DEBUG"},
+		{"loud", ""},
+		{true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			if got := jsonLevel(tt.value); got != tt.want {
+				t.Fatalf("jsonLevel(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLine(t *testing.T) {
+	dockerTs := time.Unix(1800000000, 0).UnixNano()
+	tests := []struct {
+		name    string
+		line    string
+		fields  models.JSONFields
+		message string
+		level   string
+		ts      int64
+		kept    string
+	}{
+		{
+			name:    "defaults",
+			line:    `{"level":30,"time":1700000000000,"msg":"listening","port":8080}`,
+			message: "listening", level: "INFO", ts: time.Unix(1700000000, 0).UnixNano(),
+			kept: `{"level":30,"port":8080,"time":1700000000000}`,
+		},
+		{
+			name:    "configured fields",
+			line:    `{"sev":"error","at":"14/11/2023 22:13:20","text":"disk full","msg":"kept"}`,
+			fields:  models.JSONFields{JSONLevelField: "sev", JSONTimestampField: "at", JSONTimestampFormat: "02/01/2006 15:04:05", JSONMessageField: "text"},
+			message: "disk full", level: "ERROR", ts: time.Unix(1700000000, 0).UnixNano(),
+			kept: `{"at":"14/11/2023 22:13:20","msg":"kept","sev":"error"}`,
+		},
+		{
+			name:    "unparseable fields keep the docker line's values",
+			line:    `{"level":"loud","time":"soon","msg":""}`,
+			message: `{"level":"loud","time":"soon","msg":""}`, ts: dockerTs,
+			kept: `{"level":"loud","msg":"","time":"soon"}`,
+		},
+		{
+			name:    "not json",
+			line:    "plain text",
+			message: "plain text", ts: dockerTs,
+			kept: "null",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := models.LogEntry{Message: tt.line, Timestamp: dockerTs}
+			parseJSONLine(&entry, tt.fields)
+			if entry.Message != tt.message || entry.Level != tt.level || entry.Timestamp != tt.ts {
+				t.Fatalf("got message %q, level %q, ts %d; want %q, %q, %d", entry.Message, entry.Level, entry.Timestamp, tt.message, tt.level, tt.ts)
+			}
+			kept, err := json.Marshal(entry.Fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(kept) != tt.kept {
+				t.Fatalf("fields = %s, want %s", kept, tt.kept)
+			}
+		})
+	}
+}
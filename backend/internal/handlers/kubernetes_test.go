@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/kubernetes"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func addKubernetesTestContainer(t testing.TB, s *Server) models.Container {
+	t.Helper()
+	req := &models.AddContainerRequest{Source: models.SourceKubernetes, Namespace: "default", Pod: "web"}
+	container, err := s.db.AddContainer(req, "k8s/default/web", "web", "test")
+	if err != nil {
+		t.Fatalf("add container: %v", err)
+	}
+	return *container
+}
+
+func TestStreamKubernetesContainerWithoutKubernetes(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	container := addKubernetesTestContainer(t, s)
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/containers/"+container.ID+"/stream", nil), map[string]string{"id": container.ID})
+	s.HandleStreamLogs(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if n := fake.streams(container.ContainerID); n != 0 {
+		t.Fatalf("docker streams = %d, want none", n)
+	}
+}
+
+func TestStreamKubernetesContainerFromPod(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	container := addKubernetesTestContainer(t, s)
+
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods/web/log" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "%s hello from the pod\n", time.Now().UTC().Format(time.RFC3339Nano))
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	tokenFile := filepath.Join(dir, "token")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: api.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kube, err := kubernetes.NewClient(api.URL, tokenFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.kube = kube
+
+	conn := dialWS(t, "/api/containers/{id}/stream", s.HandleStreamLogs, "/api/containers/"+container.ID+"/stream")
+	msg := readWS(t, conn, "log")
+	var entry models.LogEntry
+	if err := json.Unmarshal(msg.Payload, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "hello from the pod" {
+		t.Fatalf("streamed %q, want the pod's log line", entry.Message)
+	}
+	if n := fake.streams(container.ContainerID); n != 0 {
+		t.Fatalf("docker streams = %d, want none", n)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker/docker/api/types"
+)
+
+func TestListContainersWaitsForEveryInspect(t *testing.T) {
+	s, fake := newTestServer(t, Config{ListInspectWorkers: 3})
+	for i := 0; i < 10; i++ {
+		addTestContainer(t, s, fake, fmt.Sprintf("c%d", i))
+	}
+	// Not in the daemon's list, so every container is inspected.
+	fake.mu.Lock()
+	fake.containers = map[string]string{}
+	fake.mu.Unlock()
+	fake.inspect = func(containerID string) (*types.ContainerJSON, error) {
+		return &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+			State: &types.ContainerState{Status: "exited"},
+		}}, nil
+	}
+
+	rec := httptest.NewRecorder()
+	s.HandleListContainers(rec, httptest.NewRequest(http.MethodGet, "/api/containers", nil))
+
+	var list models.ContainerListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Containers) != 10 {
+		t.Fatalf("listed %d containers, want 10", len(list.Containers))
+	}
+	for _, c := range list.Containers {
+		if c.Status != "exited" {
+			t.Fatalf("%s listed as %q before its inspect finished, want exited", c.ContainerName, c.Status)
+		}
+	}
+}
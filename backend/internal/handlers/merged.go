@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker-logs-viewer/backend/internal/websocket"
+)
+
+const maxMergedContainers = 20
+
+func displayName(container models.Container) string {
+	if container.Alias != "" {
+		return container.Alias
+	}
+	return container.ContainerName
+}
+
+// broadcastLog sends a stored line to the container's viewers. Merged views
+// get it too and tell containers apart by its Alias.
+func (s *Server) broadcastLog(container models.Container, entry models.LogEntry) {
+	entry.Alias = displayName(container)
+	s.hub.BroadcastToContainer(container.ID, websocket.NewLogMessage(entry))
+}
+
+// mergedContainers resolves the ?ids= list of a merged view to display names
+// keyed by tracked container ID, writing the error response on failure.
+func (s *Server) mergedContainers(w http.ResponseWriter, r *http.Request) ([]string, map[string]string, bool) {
+	var ids []string
+	names := make(map[string]string)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := names[id]; ok {
+			continue
+		}
+
+		container, err := s.db.GetContainerByID(id)
+		if err != nil {
+			log.Printf("[backend] Failed to get container: %v", err)
+			s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+			return nil, nil, false
+		}
+		if container == nil {
+			s.jsonError(w, "Container not found: "+id, http.StatusNotFound)
+			return nil, nil, false
+		}
+		ids = append(ids, id)
+		names[id] = displayName(*container)
+	}
+
+	if len(ids) == 0 {
+		s.jsonError(w, "At least one container ID is required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if len(ids) > maxMergedContainers {
+		s.jsonError(w, "Too many containers, at most "+strconv.Itoa(maxMergedContainers)+" can be merged", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return ids, names, true
+}
+
+func (s *Server) getMergedLogs(ids []string, names map[string]string, limit int, before *time.Time) ([]models.LogEntry, error) {
+	logs, err := s.db.GetMergedLogs(ids, limit, before)
+	if err != nil {
+		return nil, err
+	}
+	for i := range logs {
+		logs[i].Alias = names[logs[i].TrackedContainerID]
+	}
+	return logs, nil
+}
+
+func (s *Server) HandleGetMergedLogs(w http.ResponseWriter, r *http.Request) {
+	ids, names, ok := s.mergedContainers(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxHistoryLimit)
+	}
+
+	before, err := parseTimeParam(r, "before")
+	if err != nil {
+		s.jsonError(w, "Invalid before time", http.StatusBadRequest)
+		return
+	}
+
+	logs, err := s.getMergedLogs(ids, names, limit, before)
+	if err != nil {
+		log.Printf("[backend] Failed to get merged logs: %v", err)
+		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+		return
+	}
+	if logs == nil {
+		logs = []models.LogEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LogListResponse{
+		Logs:    logs,
+		HasMore: len(logs) == limit,
+	})
+}
+
+func (s *Server) HandleWSMerged(w http.ResponseWriter, r *http.Request) {
+	ids, names, ok := s.mergedContainers(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxHistoryLimit)
+	}
+
+	if !s.acceptWSClient(w) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[websocket] Failed to upgrade merged: %v", err)
+		return
+	}
+
+	mergedIDs := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		mergedIDs[id] = true
+	}
+
+	client := &websocket.Client{
+		Conn:        conn,
+		Send:        make(chan []byte, 256),
+		Hub:         s.hub,
+		ContainerID: "merged:" + strings.Join(ids, ","),
+		MergedIDs:   mergedIDs,
+	}
+
+	s.hub.Register(client)
+	go client.WritePump()
+	go client.ReadPump()
+
+	var lastTs int64
+	for _, id := range ids {
+		ts, err := s.db.GetNewestLogTimestamp(id)
+		if err != nil {
+			log.Printf("[backend] Failed to get newest log timestamp: %v", err)
+		}
+		lastTs = max(lastTs, ts)
+	}
+	s.hub.SendToClient(client, websocket.NewHelloMessage(s.epoch, lastTs))
+
+	if r.URL.Query().Get("history") == "false" {
+		return
+	}
+
+	logs, err := s.getMergedLogs(ids, names, limit, nil)
+	if err != nil {
+		log.Printf("[backend] Failed to get existing merged logs: %v", err)
+		return
+	}
+	s.sendLogsBatch(client, logs)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/websocket"
+)
+
+func TestSystemLinesCarryAlias(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	container := addTestContainer(t, s, fake, "api")
+	container.Alias = "API"
+
+	client := &websocket.Client{Hub: s.hub, ContainerID: "merged", MergedIDs: map[string]bool{container.ID: true}, Send: make(chan []byte, 16)}
+	s.hub.Register(client)
+
+	sampler := newLogSampler(10)
+	sampler.dropped = 5
+	s.reportSampled(context.Background(), container, sampler, time.Now().UnixNano())
+
+	select {
+	case data := <-client.Send:
+		var msg websocket.WSLogMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Payload.Alias != "API" {
+			t.Fatalf("sampling marker alias = %q, want API", msg.Payload.Alias)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("merged view did not get the sampling marker")
+	}
+}
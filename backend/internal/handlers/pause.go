@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"log"
+	"slices"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/websocket"
+)
+
+// enablePause lets a log viewer pause live messages, e.g. while it is
+// scrolled up, without affecting collection. On resume it receives the logs
+// stored in the meantime as gap batches, or a fresh replacing history when
+// more than maxHistoryLimit were stored.
+func (s *Server) enablePause(client *websocket.Client, trackedContainerID string) {
+	// Lines stored before the client is registered come with its history;
+	// later ones are sent live, and OnPause gets the last of those.
+	connectSeq, err := s.db.GetLatestSeq(trackedContainerID)
+	if err != nil {
+		log.Printf("[backend] Failed to get latest seq: %v", err)
+	}
+
+	// Both hooks run on the client's read goroutine.
+	var pausedSeq int64
+	client.OnPause = func(lastSeq int64) {
+		pausedSeq = max(lastSeq, connectSeq)
+	}
+	client.OnResume = func() {
+		logs, err := s.db.GetLogsAfterSeq(trackedContainerID, pausedSeq, maxHistoryLimit+1)
+		if err != nil {
+			log.Printf("[backend] Failed to get logs stored while paused: %v", err)
+			return
+		}
+
+		if len(logs) > maxHistoryLimit {
+			logs, err = s.db.GetLogs(db.LogQuery{TrackedContainerID: trackedContainerID, Limit: maxHistoryLimit})
+			if err != nil {
+				log.Printf("[backend] Failed to get logs: %v", err)
+				return
+			}
+			s.sendLogsBatch(client, logs)
+			return
+		}
+
+		slices.Reverse(logs)
+		for start := 0; start < len(logs); start += historyChunkSize {
+			s.hub.SendToClient(client, websocket.NewGapBatchMessage(logs[start:min(start+historyChunkSize, len(logs))]))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestResumeSendsLinesQueuedWhenPaused(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	container := addTestContainer(t, s, fake, "api")
+	conn := dialWS(t, "/api/ws/{id}", s.HandleWS, "/api/ws/"+container.ID+"?history=false")
+	readWS(t, conn, "hello")
+
+	// The hub is not dispatching yet, so this line is stored but still
+	// queued when the pause arrives.
+	entry := models.LogEntry{TrackedContainerID: container.ID, ContainerID: container.ContainerID, Timestamp: time.Now().UnixNano(), Message: "queued"}
+	if err := s.storeLog(context.Background(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	s.broadcastLog(container, entry)
+
+	if err := conn.WriteJSON(map[string]string{"type": "pause"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	go s.hub.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := conn.WriteJSON(map[string]string{"type": "resume"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := readWS(t, conn, "logs_batch")
+	var logs []models.LogEntry
+	if err := json.Unmarshal(msg.Payload, &logs); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Message != "queued" {
+		t.Fatalf("gap batch = %+v, want the queued line", logs)
+	}
+}
+
+func TestResumeSkipsLinesSentBeforePause(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	container := addTestContainer(t, s, fake, "api")
+	conn := dialWS(t, "/api/ws/{id}", s.HandleWS, "/api/ws/"+container.ID+"?history=false")
+	readWS(t, conn, "hello")
+
+	store := func(message string) {
+		entry := models.LogEntry{TrackedContainerID: container.ID, ContainerID: container.ContainerID, Timestamp: time.Now().UnixNano(), Message: message}
+		if err := s.storeLog(context.Background(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		s.broadcastLog(container, entry)
+	}
+
+	store("live")
+	readWS(t, conn, "log")
+	if err := conn.WriteJSON(map[string]string{"type": "pause"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	store("while paused")
+	time.Sleep(100 * time.Millisecond)
+
+	if err := conn.WriteJSON(map[string]string{"type": "resume"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := readWS(t, conn, "logs_batch")
+	var logs []models.LogEntry
+	if err := json.Unmarshal(msg.Payload, &logs); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Message != "while paused" {
+		t.Fatalf("gap batch = %+v, want only the line stored while paused", logs)
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	longPollTimeout = 25 * time.Second
+	longPollLimit   = 500
+)
+
+// logNotifier wakes long-poll requests when a container stores new logs. Each
+// container has one channel that is closed on the next store and replaced, so
+// every waiter sees the same wakeup and can still time out with select.
+type logNotifier struct {
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+func newLogNotifier() *logNotifier {
+	return &logNotifier{waiters: make(map[string]chan struct{})}
+}
+
+func (n *logNotifier) wait(trackedContainerID string) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ch, ok := n.waiters[trackedContainerID]
+	if !ok {
+		ch = make(chan struct{})
+		n.waiters[trackedContainerID] = ch
+	}
+	return ch
+}
+
+func (n *logNotifier) notify(trackedContainerID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if ch, ok := n.waiters[trackedContainerID]; ok {
+		close(ch)
+		delete(n.waiters, trackedContainerID)
+	}
+}
+
+func (s *Server) HandlePollLogs(w http.ResponseWriter, r *http.Request) {
+	containerID := mux.Vars(r)["id"]
+
+	container, err := s.db.GetContainerByID(containerID)
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	limit := longPollLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxHistoryLimit)
+	}
+
+	// Without a cursor, hand back the current position so the client only
+	// receives logs stored from now on.
+	afterSeqStr := r.URL.Query().Get("afterSeq")
+	if afterSeqStr == "" {
+		seq, err := s.db.GetLatestSeq(container.ID)
+		if err != nil {
+			log.Printf("[backend] Failed to get latest seq: %v", err)
+			s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+			return
+		}
+		s.writePollResponse(w, nil, seq)
+		return
+	}
+
+	afterSeq, err := strconv.ParseInt(afterSeqStr, 10, 64)
+	if err != nil || afterSeq < 0 {
+		s.jsonError(w, "Invalid afterSeq", http.StatusBadRequest)
+		return
+	}
+
+	// The server's write timeout is shorter than a poll.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(longPollTimeout + 10*time.Second))
+
+	timer := time.NewTimer(longPollTimeout)
+	defer timer.Stop()
+
+	for {
+		// Subscribe before querying so a log stored in between still wakes us.
+		wake := s.logNotify.wait(container.ID)
+
+		logs, err := s.db.GetLogsAfterSeq(container.ID, afterSeq, limit)
+		if err != nil {
+			log.Printf("[backend] Failed to poll logs: %v", err)
+			s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+			return
+		}
+		if len(logs) > 0 {
+			s.writePollResponse(w, logs, logs[len(logs)-1].Seq)
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			s.writePollResponse(w, nil, afterSeq)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) writePollResponse(w http.ResponseWriter, logs []models.LogEntry, seq int64) {
+	if logs == nil {
+		logs = []models.LogEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LogPollResponse{Logs: logs, Seq: seq})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker-logs-viewer/backend/internal/websocket"
+	"github.com/gorilla/mux"
+)
+
+// HandleRelinkContainer attaches an archived container's history to a new
+// Docker container, e.g. one recreated under a different name after the old
+// one was removed with keepLogs, and resumes collecting from it.
+func (s *Server) HandleRelinkContainer(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+	if !container.Archived {
+		s.jsonError(w, "Only archived containers can be relinked", http.StatusConflict)
+		return
+	}
+	if container.Source == models.SourceKubernetes {
+		s.jsonError(w, "Kubernetes containers cannot be relinked", http.StatusBadRequest)
+		return
+	}
+
+	var req models.RelinkContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimLeft(strings.TrimSpace(req.Name), "/")
+	if req.Name == "" {
+		s.jsonError(w, "Container name is required", http.StatusBadRequest)
+		return
+	}
+
+	dc := s.dockerFor(*container)
+	target, err := dc.FindContainerByName(r.Context(), req.Name)
+	if err != nil {
+		log.Printf("[backend] Failed to find container: %v", err)
+		s.jsonError(w, "Failed to find container", http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		s.containerNotFound(w, r, dc, &models.AddContainerRequest{Name: req.Name})
+		return
+	}
+
+	// Archived rows count too: relinking another one to the same container
+	// would split its history across two entries.
+	tracked, err := s.db.GetContainersSorted("addedAt", true, nil, true)
+	if err != nil {
+		log.Printf("[backend] Failed to get existing containers: %v", err)
+		s.jsonError(w, "Failed to get containers", http.StatusInternalServerError)
+		return
+	}
+	for _, c := range tracked {
+		if c.ID == container.ID {
+			continue
+		}
+		if c.ContainerID == target.ID {
+			s.jsonError(w, fmt.Sprintf("Container is already tracked as %s", displayName(c)), http.StatusConflict)
+			return
+		}
+		if c.Archived && strings.HasPrefix(c.ContainerID, target.ID+":") {
+			s.jsonError(w, fmt.Sprintf("Container is already archived as %s; relink that one instead", displayName(c)), http.StatusConflict)
+			return
+		}
+	}
+
+	newName := req.Name
+	if len(target.Names) > 0 {
+		newName = strings.TrimPrefix(target.Names[0], "/")
+	}
+
+	oldID := container.ContainerID
+	lastLogTs, err := s.db.RelinkContainer(container.ID, target.ID, newName)
+	if err != nil {
+		log.Printf("[backend] Failed to relink container: %v", err)
+		s.jsonError(w, "Failed to relink container", http.StatusInternalServerError)
+		return
+	}
+
+	relinkTimestamp := max(time.Now().UnixNano(), lastLogTs+1)
+	if s.config.QuietSystemLogs {
+		log.Printf("[backend] Container %s relinked from %s to %s", container.ContainerName, oldID[:12], target.ID[:12])
+	} else if _, err := s.addSystemLog(r.Context(), container.ID, target.ID, relinkTimestamp,
+		fmt.Sprintf("Container relinked from %s (%s) to %s (%s)", container.ContainerName, oldID[:12], newName, target.ID[:12])); err != nil {
+		log.Printf("[backend] Failed to add system log: %v", err)
+	}
+
+	relinked, err := s.db.GetContainerByID(container.ID)
+	if err != nil || relinked == nil {
+		log.Printf("[backend] Failed to get relinked container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+
+	s.startCollection(context.Background(), *relinked)
+	s.hub.BroadcastToContainer("containers", websocket.NewContainerAddedMessage(*relinked))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AddContainerResponse{
+		Container: *relinked,
+		Success:   true,
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRelinkRejectsContainerWithArchivedHistory(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	a := addTestContainer(t, s, fake, "a")
+	b := addTestContainer(t, s, fake, "b")
+	for _, id := range []string{a.ID, b.ID} {
+		if err := s.db.ArchiveContainer(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := addTestContainer(t, s, fake, "target")
+	if err := s.db.ArchiveContainer(target.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	relink := func(id, name string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/containers/"+id+"/relink", strings.NewReader(`{"name":"`+name+`"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		s.HandleRelinkContainer(rec, req)
+		return rec.Code
+	}
+
+	if code := relink(a.ID, "target"); code != http.StatusConflict {
+		t.Fatalf("relink to a container archived elsewhere = %d, want 409", code)
+	}
+	if code := relink(a.ID, "b"); code != http.StatusConflict {
+		t.Fatalf("relink to b's container = %d, want 409", code)
+	}
+	// A container's own archived history is what relinking is for.
+	if code := relink(target.ID, "target"); code != http.StatusOK {
+		t.Fatalf("relink to its own container = %d, want 200", code)
+	}
+	if code := relink(b.ID, "target"); code != http.StatusConflict {
+		t.Fatalf("relink to a tracked container = %d, want 409", code)
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import "github.com/docker-logs-viewer/backend/internal/models"
+
+// repeatCounter numbers lines of one stream that repeat the timestamp and
+// text of an earlier line, so they are stored as separate rows instead of
+// being dropped as duplicates. A resumed stream reads the repeats again in
+// the same order and gets the same numbers, so replays still deduplicate.
+type repeatCounter struct {
+	timestamp int64
+	seen      map[string]int
+}
+
+func (c *repeatCounter) occurrence(entry models.LogEntry) int {
+	if c.seen == nil || entry.Timestamp != c.timestamp {
+		c.timestamp = entry.Timestamp
+		c.seen = make(map[string]int)
+	}
+	n := c.seen[entry.Message]
+	c.seen[entry.Message] = n + 1
+	return n
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+)
+
+func TestRepeatedLinesKeptAndReplaysDeduplicated(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	container := addTestContainer(t, s, fake, "app")
+
+	// Two identical lines in the same nanosecond, then another one.
+	ts := time.Now()
+	stream := func() <-chan docker.LogMessage {
+		ch := make(chan docker.LogMessage, 3)
+		ch <- docker.LogMessage{Log: "retrying", Timestamp: ts}
+		ch <- docker.LogMessage{Log: "retrying", Timestamp: ts}
+		ch <- docker.LogMessage{Log: "retrying", Timestamp: ts.Add(time.Millisecond)}
+		close(ch)
+		return ch
+	}
+
+	ctx := context.Background()
+	want := []string{"retrying", "retrying", "retrying"}
+
+	s.ingestLogs(ctx, container, stream())
+	if got := storedMessages(t, s, container.ID); !slices.Equal(got, want) {
+		t.Fatalf("after first read: %q, want %q", got, want)
+	}
+
+	// A resumed stream reads the same lines again.
+	s.ingestLogs(ctx, container, stream())
+	if got := storedMessages(t, s, container.ID); !slices.Equal(got, want) {
+		t.Fatalf("after replay: %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/docker"
+)
+
+// sinceStream answers like a daemon that honors Since to the nanosecond,
+// including the line at exactly Since.
+func sinceStream(lines []docker.LogMessage, opts docker.StreamOptions) <-chan docker.LogMessage {
+	ch := make(chan docker.LogMessage, len(lines))
+	for _, line := range lines {
+		if !line.Timestamp.Before(opts.Since) {
+			ch <- line
+		}
+	}
+	close(ch)
+	return ch
+}
+
+func TestResumeAfterRestartHasNoGapOrDuplicate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	fake := newFakeDocker()
+	// All lines fall within the same second, so a second granular since
+	// would re-read or skip some of them.
+	base := time.Now().Add(time.Second).Truncate(time.Second)
+	var daemon []docker.LogMessage
+	write := func(from, to int) {
+		for i := from; i <= to; i++ {
+			daemon = append(daemon, docker.LogMessage{Log: fmt.Sprintf("line %d", i), Timestamp: base.Add(time.Duration(i) * 100), Stream: "stdout"})
+		}
+	}
+	var sinces []time.Time
+	fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+		sinces = append(sinces, opts.Since)
+		return sinceStream(daemon, opts), nil
+	}
+
+	start := func() (*Server, *db.SQLiteDB) {
+		database, err := db.NewSQLiteDB(path, db.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewServer(database, nil, Config{})
+		s.docker = fake
+		return s, database
+	}
+
+	s, database := start()
+	container := addTestContainer(t, s, fake, "api")
+	write(1, 3)
+	s.collectLogsForContainer(context.Background(), container, false, func() {})
+	database.Close()
+
+	// The container keeps logging while the server is down.
+	write(4, 5)
+	s, database = start()
+	defer database.Close()
+	s.collectLogsForContainer(context.Background(), container, false, func() {})
+
+	if got := storedMessages(t, s, container.ID); fmt.Sprint(got) != "[line 1 line 2 line 3 line 4 line 5]" {
+		t.Fatalf("stored %q, want every line once", got)
+	}
+	if want := base.Add(300); !sinces[1].Equal(want) {
+		t.Fatalf("resumed from %s, want the last stored line at %s", sinces[1].Format(time.RFC3339Nano), want.Format(time.RFC3339Nano))
+	}
+}
+
+func TestResumeSkewCoversContainerClockAhead(t *testing.T) {
+	const skew = 5 * time.Second
+	for _, tc := range []struct {
+		resumeSkew time.Duration
+		want       string
+	}{
+		// Resuming from the last stored line misses what the daemon logged
+		// in the 5s before it by its own clock.
+		{0, "[line 1 line 2 line 3]"},
+		{skew + time.Second, "[line 1 line 2 line 3 line 4 line 5]"},
+	} {
+		t.Run(fmt.Sprintf("skew=%s", tc.resumeSkew), func(t *testing.T) {
+			s, fake := newTestServer(t, Config{ResumeSkew: tc.resumeSkew})
+			container := addTestContainer(t, s, fake, "api")
+
+			// Lines carry the container's timestamps, 5s ahead of the
+			// host clock the daemon filters since by.
+			type line struct {
+				host time.Time
+				msg  docker.LogMessage
+			}
+			base := time.Now().Add(time.Second)
+			var daemon []line
+			write := func(from, to int) {
+				for i := from; i <= to; i++ {
+					host := base.Add(time.Duration(i) * time.Second)
+					daemon = append(daemon, line{host, docker.LogMessage{Log: fmt.Sprintf("line %d", i), Timestamp: host.Add(skew), Stream: "stdout"}})
+				}
+			}
+			fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+				ch := make(chan docker.LogMessage, len(daemon))
+				for _, l := range daemon {
+					if !l.host.Before(opts.Since) {
+						ch <- l.msg
+					}
+				}
+				close(ch)
+				return ch, nil
+			}
+
+			write(1, 3)
+			s.collectLogsForContainer(context.Background(), container, false, func() {})
+			write(4, 5)
+			s.collectLogsForContainer(context.Background(), container, false, func() {})
+
+			if got := storedMessages(t, s, container.ID); fmt.Sprint(got) != tc.want {
+				t.Fatalf("stored %q, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// rotates reports whether the driver only keeps a bounded amount of output:
+// local always rotates, json-file only with max-size.
+func rotates(logConfig models.LogConfig) bool {
+	switch logConfig.Type {
+	case "local":
+		return true
+	case "json-file":
+		return logConfig.Config["max-size"] != ""
+	}
+	return false
+}
+
+// checkLogRotation records the container's logging driver configuration and,
+// when the driver rotates, warns if lines between since and the oldest line
+// Docker still holds were rotated away while nothing was collecting them.
+func (s *Server) checkLogRotation(ctx context.Context, container models.Container, dockerID string, since time.Time) {
+	dc := s.dockerFor(container)
+	info, err := dc.InspectContainer(ctx, dockerID)
+	if err != nil || info.ContainerJSONBase == nil || info.HostConfig == nil {
+		return
+	}
+	logConfig := models.LogConfig{Type: info.HostConfig.LogConfig.Type, Config: info.HostConfig.LogConfig.Config}
+	if container.LogConfig == nil || container.LogConfig.Type != logConfig.Type || !maps.Equal(container.LogConfig.Config, logConfig.Config) {
+		if err := s.db.SetLogConfig(container.ID, logConfig); err != nil {
+			log.Printf("[backend] Failed to store log config of %s: %v", container.ContainerName, err)
+		}
+	}
+
+	if !rotates(logConfig) {
+		return
+	}
+	// A container created after since (e.g. the replacement after a swap)
+	// never had the older lines.
+	if created, err := time.Parse(time.RFC3339Nano, info.Created); err == nil && created.After(since) {
+		return
+	}
+	oldest, err := dc.OldestLogTimestamp(ctx, dockerID)
+	if err != nil {
+		log.Printf("[backend] Failed to read oldest log of %s: %v", container.ContainerName, err)
+		return
+	}
+	if oldest.IsZero() || !oldest.After(since) {
+		return
+	}
+
+	log.Printf("[backend] Logs of %s between %s and %s were rotated out by the %s driver", container.ContainerName,
+		since.UTC().Format(time.RFC3339), oldest.UTC().Format(time.RFC3339), logConfig.Type)
+	entry, err := s.addSystemLog(ctx, container.ID, dockerID, oldest.UnixNano()-1,
+		fmt.Sprintf("Logs between %s and %s were rotated out by the %s log driver (max-size %s, max-file %s) before they could be collected and are lost",
+			since.UTC().Format(time.RFC3339), oldest.UTC().Format(time.RFC3339), logConfig.Type,
+			optionOrDefault(logConfig.Config, "max-size"), optionOrDefault(logConfig.Config, "max-file")))
+	if err != nil {
+		log.Printf("[backend] Failed to add system log: %v", err)
+		return
+	}
+	s.broadcastLog(container, entry)
+}
+
+func optionOrDefault(options map[string]string, key string) string {
+	if value := options[key]; value != "" {
+		return value
+	}
+	return "default"
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const sampleReportInterval = time.Minute
+
+// logSampler keeps one of every rate lines and counts the rest until they
+// are reported.
+type logSampler struct {
+	rate       int
+	seen       int
+	dropped    int
+	lastReport time.Time
+}
+
+func newLogSampler(rate int) *logSampler {
+	return &logSampler{rate: max(rate, 1), lastReport: time.Now()}
+}
+
+func (l *logSampler) keep() bool {
+	l.seen++
+	if l.rate == 1 || l.seen%l.rate == 1 {
+		return true
+	}
+	l.dropped++
+	return false
+}
+
+func (l *logSampler) reportDue() bool {
+	return l.dropped > 0 && time.Since(l.lastReport) >= sampleReportInterval
+}
+
+// reportSampled stores a marker saying how many lines sampling dropped since
+// the last marker.
+func (s *Server) reportSampled(ctx context.Context, container models.Container, sampler *logSampler, timestamp int64) {
+	if sampler.dropped == 0 {
+		return
+	}
+	dropped := sampler.dropped
+	sampler.dropped = 0
+	sampler.lastReport = time.Now()
+
+	entry, err := s.addSystemLog(ctx, container.ID, container.ContainerID, timestamp,
+		fmt.Sprintf("Sampling dropped %d lines (keeping 1 of every %d)", dropped, sampler.rate))
+	if err != nil {
+		if !errors.Is(err, errIngestionPaused) {
+			log.Printf("[backend] Failed to add sampling marker for %s: %v", container.ContainerName, err)
+		}
+		return
+	}
+	s.broadcastLog(container, entry)
+}
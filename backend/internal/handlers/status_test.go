@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/mux"
+)
+
+func TestIntermittentInspectFailuresDoNotMarkUnknown(t *testing.T) {
+	s, fake := newTestServer(t, Config{UnknownAfterFailures: 3})
+	container := addTestContainer(t, s, fake, "api")
+	// Not in the daemon's list, so every status check inspects it.
+	delete(fake.containers, "api")
+
+	var failing atomic.Bool
+	fake.inspect = func(containerID string) (*types.ContainerJSON, error) {
+		if failing.Load() {
+			return nil, errors.New("context deadline exceeded")
+		}
+		return &types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+			State: &types.ContainerState{Status: "running"},
+		}}, nil
+	}
+	status := func() string {
+		c, err := s.db.GetContainerByID(container.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Status
+	}
+
+	ctx := context.Background()
+	s.checkContainerUpdates(ctx)
+	if got := status(); got != "running" {
+		t.Fatalf("status = %s, want running", got)
+	}
+
+	// Listing containers in between passes must not count toward unknown.
+	failing.Store(true)
+	for i := 0; i < 5; i++ {
+		containers := []models.Container{container}
+		s.refreshContainerStatus(ctx, &containers[0], time.Second)
+	}
+	s.checkContainerUpdates(ctx)
+	s.checkContainerUpdates(ctx)
+	failing.Store(false)
+	s.checkContainerUpdates(ctx)
+	failing.Store(true)
+	s.checkContainerUpdates(ctx)
+	s.checkContainerUpdates(ctx)
+	if got := status(); got != "running" {
+		t.Fatalf("status after intermittent failures = %s, want running", got)
+	}
+
+	s.checkContainerUpdates(ctx)
+	if got := status(); got != "unknown" {
+		t.Fatalf("status after 3 failed passes in a row = %s, want unknown", got)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/containers/"+container.ID, nil), map[string]string{"id": container.ID})
+	s.HandleRemoveContainer(httptest.NewRecorder(), req)
+	s.inspectMu.Lock()
+	defer s.inspectMu.Unlock()
+	if _, ok := s.inspectFailures[container.ID]; ok {
+		t.Fatal("inspect failures of a removed container are still tracked")
+	}
+}
+
+func TestPartialInspectIsUnknown(t *testing.T) {
+	for name, info := range map[string]*types.ContainerJSON{
+		"no state": {ContainerJSONBase: &types.ContainerJSONBase{}},
+		"no base":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, fake := newTestServer(t, Config{})
+			container := addTestContainer(t, s, fake, "api")
+			// Not in the daemon's list, so the status check inspects it.
+			delete(fake.containers, "api")
+			fake.inspect = func(containerID string) (*types.ContainerJSON, error) { return info, nil }
+
+			s.checkContainerUpdates(context.Background())
+			c, err := s.db.GetContainerByID(container.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Status != "unknown" {
+				t.Fatalf("status = %s, want unknown", c.Status)
+			}
+
+			containers := []models.Container{*c}
+			s.refreshContainerStatus(context.Background(), &containers[0], time.Second)
+			if containers[0].Status != "unknown" {
+				t.Fatalf("listed status = %s, want unknown", containers[0].Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+func TestStreamSessionAdvancesLastLogTimestamp(t *testing.T) {
+	s, fake := newTestServer(t, Config{})
+	go s.hub.Run()
+	container := addTestContainer(t, s, fake, "api")
+	start := time.Now().Add(time.Minute)
+	if err := s.db.UpdateLastLogTimestamp(container.ID, start.UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	var at time.Time
+	fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+		return lineStream(at, lines...), nil
+	}
+	session := func() {
+		t.Helper()
+		conn := dialWS(t, "/api/containers/{id}/stream", s.HandleStreamLogs, "/api/containers/"+container.ID+"/stream")
+		// Sent once the stream has ended and its position was recorded.
+		readWS(t, conn, "container_unavailable")
+	}
+	lastLog := func() int64 {
+		t.Helper()
+		ts, err := s.db.GetLastLogTimestamp(container.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	at, lines = start.Add(time.Second), []string{"newer", "newest"}
+	session()
+	want := at.Add(time.Millisecond).UnixNano()
+	waitFor(t, "the stream's last log timestamp", func() bool { return lastLog() == want })
+
+	// Replayed history is stored but leaves the position alone.
+	at, lines = start.Add(-time.Hour), []string{"older"}
+	session()
+	waitFor(t, "the replayed line", func() bool { return len(storedMessages(t, s, container.ID)) == 3 })
+	// Give the handler time to record its position after closing the stream.
+	time.Sleep(100 * time.Millisecond)
+	if got := lastLog(); got != want {
+		t.Fatalf("last log timestamp after replaying history = %d, want %d", got, want)
+	}
+}
+
+// BenchmarkStreamInlineRetention streams b.N lines through /stream for a
+// container with a line limit, with inline retention on and off, to show what
+// the count and delete after every stored line costs.
+func BenchmarkStreamInlineRetention(b *testing.B) {
+	for _, inline := range []bool{true, false} {
+		b.Run(fmt.Sprintf("inline=%t", inline), func(b *testing.B) {
+			s, fake := newTestServer(b, Config{})
+			go s.hub.Run()
+			fake.containers["api"] = dockerID("api")
+			req := &models.AddContainerRequest{Name: "api", MaxLines: 1000, InlineRetention: &inline}
+			container, err := s.db.AddContainer(req, dockerID("api"), "api", "test")
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			lines := make([]string, b.N)
+			for i := range lines {
+				lines[i] = fmt.Sprintf("request %d handled in 12ms", i)
+			}
+			fake.stream = func(ctx context.Context, containerID string, opts docker.StreamOptions) (<-chan docker.LogMessage, error) {
+				return lineStream(time.Now(), lines...), nil
+			}
+
+			b.ResetTimer()
+			conn := dialWS(b, "/api/containers/{id}/stream", s.HandleStreamLogs, "/api/containers/"+container.ID+"/stream")
+			// Sent once every line went through.
+			conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+			for {
+				var msg wsMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					b.Fatal(err)
+				}
+				if msg.Type == "container_unavailable" {
+					break
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "lines/s")
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/docker-logs-viewer/backend/internal/db"
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxTransformRules     = 20
+	transformPreviewLimit = 20
+	maxTransformPreview   = 200
+)
+
+type transformRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// transformer applies a container's transformation rules in order. A nil
+// transformer leaves lines unchanged.
+type transformer []transformRule
+
+func compileTransforms(rules []models.TransformRule) (transformer, error) {
+	if len(rules) > maxTransformRules {
+		return nil, fmt.Errorf("At most %d transforms are allowed", maxTransformRules)
+	}
+
+	var t transformer
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("Transform %d has an empty pattern", i+1)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Transform %d has an invalid pattern: %v", i+1, err)
+		}
+		t = append(t, transformRule{pattern: pattern, replacement: rule.Replacement})
+	}
+	return t, nil
+}
+
+// containerTransforms compiles the stored rules of a container for one
+// collection pass. Rules are validated when saved, so a failure here only
+// means they were edited in the database by hand.
+func containerTransforms(container models.Container) transformer {
+	t, err := compileTransforms(container.Transforms)
+	if err != nil {
+		log.Printf("[backend] Ignoring transforms of %s: %v", container.ContainerName, err)
+	}
+	return t
+}
+
+func (t transformer) apply(message string) string {
+	for _, rule := range t {
+		message = rule.pattern.ReplaceAllString(message, rule.replacement)
+	}
+	return message
+}
+
+// HandlePreviewTransforms runs rules against a container's most recent stored
+// lines without saving them or touching the stored logs.
+func (s *Server) HandlePreviewTransforms(w http.ResponseWriter, r *http.Request) {
+	container, err := s.db.GetContainerByID(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("[backend] Failed to get container: %v", err)
+		s.jsonError(w, "Failed to get container", http.StatusInternalServerError)
+		return
+	}
+	if container == nil {
+		s.jsonError(w, "Container not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.TransformPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	t, err := compileTransforms(req.Transforms)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := transformPreviewLimit
+	if req.Limit > 0 {
+		limit = min(req.Limit, maxTransformPreview)
+	}
+
+	logs, err := s.db.GetLogs(db.LogQuery{TrackedContainerID: container.ID, Limit: limit})
+	if err != nil {
+		log.Printf("[backend] Failed to get logs for transform preview: %v", err)
+		s.jsonError(w, "Failed to get logs", http.StatusInternalServerError)
+		return
+	}
+
+	lines := make([]models.TransformPreviewLine, 0, len(logs))
+	for _, entry := range logs {
+		transformed := t.apply(entry.Message)
+		lines = append(lines, models.TransformPreviewLine{
+			ID:          entry.ID,
+			Message:     entry.Message,
+			Transformed: transformed,
+			Changed:     transformed != entry.Message,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TransformPreviewResponse{Lines: lines})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+type statusChangePayload struct {
+	ContainerID string `json:"containerId"`
+	Alias       string `json:"alias"`
+	OldStatus   string `json:"oldStatus"`
+	NewStatus   string `json:"newStatus"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+type pendingStatusChange struct {
+	payload statusChangePayload
+	timer   *time.Timer
+}
+
+// statusWebhook posts status transitions once they have held for the debounce
+// window, so a container flapping between states within it sends nothing.
+type statusWebhook struct {
+	url      string
+	debounce time.Duration
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending map[string]*pendingStatusChange
+}
+
+func newStatusWebhook(url string, debounce time.Duration) *statusWebhook {
+	return &statusWebhook{
+		url:      url,
+		debounce: debounce,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		pending:  make(map[string]*pendingStatusChange),
+	}
+}
+
+func (w *statusWebhook) Notify(container models.Container, oldStatus, newStatus string) {
+	alias := container.Alias
+	if alias == "" {
+		alias = container.ContainerName
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if p, ok := w.pending[container.ID]; ok {
+		p.timer.Stop()
+		if p.payload.OldStatus == newStatus {
+			delete(w.pending, container.ID)
+			return
+		}
+		oldStatus = p.payload.OldStatus
+	}
+
+	p := &pendingStatusChange{
+		payload: statusChangePayload{
+			ContainerID: container.ID,
+			Alias:       alias,
+			OldStatus:   oldStatus,
+			NewStatus:   newStatus,
+			Timestamp:   time.Now().Unix(),
+		},
+	}
+	p.timer = time.AfterFunc(w.debounce, func() {
+		w.mu.Lock()
+		if w.pending[container.ID] != p {
+			w.mu.Unlock()
+			return
+		}
+		delete(w.pending, container.ID)
+		w.mu.Unlock()
+
+		w.send(p.payload)
+	})
+	w.pending[container.ID] = p
+}
+
+func (w *statusWebhook) send(payload statusChangePayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[backend] Failed to encode status webhook: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[backend] Failed to create status webhook request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		log.Printf("[backend] Status webhook failed for %s: %v", payload.Alias, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("[backend] Status webhook for %s returned %s", payload.Alias, resp.Status)
+	}
+}
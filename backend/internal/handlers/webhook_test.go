@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const testDebounce = 100 * time.Millisecond
+
+// newTestWebhook returns a webhook posting to a server that passes every
+// payload it receives on the returned channel.
+func newTestWebhook(t *testing.T) (*statusWebhook, <-chan statusChangePayload) {
+	t.Helper()
+	posts := make(chan statusChangePayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload statusChangePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		posts <- payload
+	}))
+	t.Cleanup(server.Close)
+	return newStatusWebhook(server.URL, testDebounce), posts
+}
+
+// expectPosts waits out a few debounce windows and returns what was posted.
+func expectPosts(posts <-chan statusChangePayload) []statusChangePayload {
+	var got []statusChangePayload
+	timeout := time.After(5 * testDebounce)
+	for {
+		select {
+		case payload := <-posts:
+			got = append(got, payload)
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+func TestWebhookFlapWithinDebounceSendsNothing(t *testing.T) {
+	w, posts := newTestWebhook(t)
+	container := models.Container{ID: "c1", ContainerName: "web"}
+
+	w.Notify(container, "running", "exited")
+	time.Sleep(testDebounce / 4)
+	w.Notify(container, "exited", "running")
+
+	if got := expectPosts(posts); len(got) != 0 {
+		t.Fatalf("posted %+v, want nothing for a flap", got)
+	}
+}
+
+func TestWebhookHeldStatusSendsOnce(t *testing.T) {
+	w, posts := newTestWebhook(t)
+	container := models.Container{ID: "c1", ContainerName: "web", Alias: "frontend"}
+
+	w.Notify(container, "running", "exited")
+
+	got := expectPosts(posts)
+	if len(got) != 1 {
+		t.Fatalf("posted %d times, want once", len(got))
+	}
+	if got[0].ContainerID != "c1" || got[0].Alias != "frontend" || got[0].OldStatus != "running" || got[0].NewStatus != "exited" {
+		t.Fatalf("posted %+v", got[0])
+	}
+}
+
+func TestWebhookLaterChangeSupersedesPending(t *testing.T) {
+	w, posts := newTestWebhook(t)
+	container := models.Container{ID: "c1", ContainerName: "web"}
+
+	w.Notify(container, "running", "restarting")
+	time.Sleep(testDebounce / 4)
+	w.Notify(container, "restarting", "exited")
+
+	got := expectPosts(posts)
+	if len(got) != 1 || got[0].OldStatus != "running" || got[0].NewStatus != "exited" {
+		t.Fatalf("posted %+v, want one running -> exited change", got)
+	}
+	if got[0].Alias != "web" {
+		t.Fatalf("alias = %q, want the container name when no alias is set", got[0].Alias)
+	}
+}
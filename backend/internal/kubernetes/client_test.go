@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/docker"
+)
+
+// newTestClient serves handler over TLS and returns a client trusting it,
+// with "secret" as its service account token.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	api := httptest.NewTLSServer(handler)
+	t.Cleanup(api.Close)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	tokenFile := filepath.Join(dir, "token")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: api.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(api.URL, tokenFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewClientOutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	if _, err := NewClient("", "", ""); err == nil {
+		t.Fatal("expected an error without an API server")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient("https://api:6443", "", caFile); err == nil {
+		t.Fatal("expected an error for a CA file without certificates")
+	}
+}
+
+func TestStreamPodLogs(t *testing.T) {
+	since := time.Date(2026, 10, 15, 12, 0, 0, 500_000_000, time.UTC)
+	var query url.Values
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/pods/web-1/log" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		query = r.URL.Query()
+		// Lines from the start of the second, as a server that truncates
+		// sinceTime would send them.
+		fmt.Fprintf(w, "%s before\n", since.Add(-400*time.Millisecond).Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "%s at\n", since.Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "%s after\n", since.Add(time.Millisecond).Format(time.RFC3339Nano))
+	})
+
+	logs, err := c.StreamPodLogs(context.Background(), "prod", "web-1", "app", docker.StreamOptions{Since: since, Tail: 10, NoFollow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for entry := range logs {
+		if entry.Container != "prod/web-1" {
+			t.Fatalf("container = %q", entry.Container)
+		}
+		got = append(got, fmt.Sprintf("%s %s", entry.Timestamp.Format(time.RFC3339Nano), entry.Log))
+	}
+
+	want := []string{
+		since.Format(time.RFC3339Nano) + " at",
+		since.Add(time.Millisecond).Format(time.RFC3339Nano) + " after",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("streamed %q, want %q", got, want)
+	}
+
+	if query.Get("sinceTime") != "2026-10-15T12:00:00.5Z" {
+		t.Fatalf("sinceTime = %q, want nanosecond precision", query.Get("sinceTime"))
+	}
+	if query.Get("tailLines") != "10" || query.Get("container") != "app" || query.Get("follow") != "false" || query.Get("timestamps") != "true" {
+		t.Fatalf("query = %v", query)
+	}
+}
+
+func TestStreamPodLogsError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `pods "web-1" not found`, http.StatusNotFound)
+	})
+	if _, err := c.StreamPodLogs(context.Background(), "prod", "web-1", "", docker.StreamOptions{}); err == nil {
+		t.Fatal("expected an error for a missing pod")
+	}
+}
+
+func TestPodStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		container string
+		pod       string
+		want      string
+	}{
+		{"running container", "app", `{"status":{"phase":"Running","containerStatuses":[{"name":"app","state":{"running":{}}}]}}`, "running"},
+		{"waiting container", "app", `{"status":{"phase":"Running","containerStatuses":[{"name":"app","state":{"waiting":{}}}]}}`, "restarting"},
+		{"terminated container", "app", `{"status":{"phase":"Running","containerStatuses":[{"name":"sidecar","state":{"running":{}}},{"name":"app","state":{"terminated":{}}}]}}`, "exited"},
+		{"first container", "", `{"status":{"phase":"Running","containerStatuses":[{"name":"app","state":{"terminated":{}}}]}}`, "exited"},
+		{"pending pod", "app", `{"status":{"phase":"Pending"}}`, "restarting"},
+		{"succeeded pod", "app", `{"status":{"phase":"Succeeded"}}`, "exited"},
+		{"unknown phase", "app", `{"status":{"phase":"Unknown"}}`, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/namespaces/prod/pods/web-1" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(tt.pod))
+			})
+			got, err := c.PodStatus(context.Background(), "prod", "web-1", tt.container)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("PodStatus = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTimestamp(t *testing.T) {
+	ts, message := splitTimestamp("2026-10-15T12:00:00.123456789Z hello world")
+	if message != "hello world" || ts.Nanosecond() != 123456789 {
+		t.Fatalf("got %v %q", ts, message)
+	}
+
+	// Lines without a timestamp are kept whole and stamped on arrival.
+	before := time.Now()
+	ts, message = splitTimestamp("no timestamp here")
+	if message != "no timestamp here" || ts.Before(before) {
+		t.Fatalf("got %v %q", ts, message)
+	}
+}
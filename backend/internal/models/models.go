@@ -1,16 +1,58 @@
 package models
 
+const (
+	SourceDocker     = "docker"
+	SourceKubernetes = "kubernetes"
+
+	LogFormatAuto     = "auto"
+	LogFormatDocker   = "docker"
+	LogFormatJournald = "journald"
+	LogFormatJSON     = "json"
+)
+
 type Container struct {
-	ID            string `json:"id" db:"id"`
-	ContainerID   string `json:"containerId" db:"container_id"`
-	ContainerName string `json:"containerName" db:"container_name"`
-	Alias         string `json:"alias" db:"alias"`
-	AddedAt       int64  `json:"addedAt" db:"added_at"`
-	SwappedAt     int64  `json:"swappedAt" db:"swapped_at"`
-	Status        string `json:"status" db:"status"`
-	MaxPeriod     int64  `json:"maxPeriod" db:"max_period"`
-	MaxLines      int    `json:"maxLines" db:"max_lines"`
-	ServerName    string `json:"serverName" db:"server_name"`
+	ID              string            `json:"id" db:"id"`
+	ContainerID     string            `json:"containerId" db:"container_id"`
+	ContainerName   string            `json:"containerName" db:"container_name"`
+	Alias           string            `json:"alias" db:"alias"`
+	AddedAt         int64             `json:"addedAt" db:"added_at"`
+	SwappedAt       int64             `json:"swappedAt" db:"swapped_at"`
+	Status          string            `json:"status" db:"status"`
+	MaxPeriod       int64             `json:"maxPeriod" db:"max_period"`
+	MaxLines        int               `json:"maxLines" db:"max_lines"`
+	ServerName      string            `json:"serverName" db:"server_name"`
+	ExpectedMaxGap  int64             `json:"expectedMaxGap" db:"expected_max_gap"`
+	Metadata        map[string]string `json:"metadata" db:"metadata"`
+	Source          string            `json:"source" db:"source"`
+	Namespace       string            `json:"namespace,omitempty" db:"k8s_namespace"`
+	Pod             string            `json:"pod,omitempty" db:"k8s_pod"`
+	PodContainer    string            `json:"podContainer,omitempty" db:"k8s_container"`
+	LogDetails      bool              `json:"logDetails" db:"log_details"`
+	DetailKeys      []string          `json:"detailKeys,omitempty" db:"detail_keys"`
+	LogFormat       string            `json:"logFormat" db:"log_format"`
+	CollectStdout   bool              `json:"collectStdout" db:"collect_stdout"`
+	CollectStderr   bool              `json:"collectStderr" db:"collect_stderr"`
+	InitialLines    int               `json:"initialLines,omitempty" db:"initial_lines"`
+	SampleRate      int               `json:"sampleRate" db:"sample_rate"`
+	CrashLooping    bool              `json:"crashLooping,omitempty" db:"crash_looping"`
+	LogConfig       *LogConfig        `json:"logConfig,omitempty" db:"log_config"`
+	StartedAt       int64             `json:"startedAt,omitempty" db:"started_at"`
+	Archived        bool              `json:"archived,omitempty" db:"archived"`
+	Transforms      []TransformRule   `json:"transforms,omitempty" db:"transforms"`
+	InlineRetention bool              `json:"inlineRetention" db:"inline_retention"`
+	SyslogForward   bool              `json:"syslogForward,omitempty" db:"syslog_forward"`
+	DockerHost      string            `json:"dockerHost,omitempty" db:"docker_host"`
+	JSONFields
+
+	RetentionUnlimited bool  `json:"retentionUnlimited,omitempty" db:"-"`
+	LogBytes           int64 `json:"logBytes" db:"-"`
+}
+
+// LogConfig is a Docker container's logging driver and its options, e.g.
+// json-file with max-size and max-file.
+type LogConfig struct {
+	Type   string            `json:"type"`
+	Config map[string]string `json:"config,omitempty"`
 }
 
 type LogEntry struct {
@@ -19,28 +61,126 @@ type LogEntry struct {
 	TrackedContainerID string `json:"-" db:"tracked_container_id"`
 	Timestamp          int64  `json:"timestamp" db:"timestamp"`
 	Message            string `json:"message" db:"message"`
+	Truncated          bool   `json:"truncated,omitempty" db:"-"`
+	LocalTime          string `json:"localTime,omitempty" db:"-"`
+	Seq                int64  `json:"seq,omitempty" db:"-"`
+	Pinned             bool   `json:"pinned,omitempty" db:"pinned"`
+	Level              string `json:"level,omitempty" db:"level"`
+	Stream             string `json:"stream,omitempty" db:"stream"`
+	Occurrence         int    `json:"-" db:"occurrence"`
+	Alias              string `json:"alias,omitempty" db:"-"`
+
+	Details     map[string]string      `json:"details,omitempty" db:"details"`
+	Fields      map[string]interface{} `json:"fields,omitempty" db:"fields"`
+	Annotations []Annotation           `json:"annotations,omitempty" db:"-"`
+}
+
+type Annotation struct {
+	ID        string `json:"id" db:"id"`
+	LogID     string `json:"logId" db:"log_id"`
+	Note      string `json:"note" db:"note"`
+	Author    string `json:"author,omitempty" db:"author"`
+	CreatedAt int64  `json:"createdAt" db:"created_at"`
+}
+
+type AnnotateLogRequest struct {
+	Note   string `json:"note"`
+	Author string `json:"author,omitempty"`
 }
 
 type AddContainerRequest struct {
-	Name       string `json:"name" validate:"required"`
-	Alias      string `json:"alias,omitempty"`
-	MaxPeriod  int64  `json:"maxPeriod,omitempty"`
-	MaxLines   int    `json:"maxLines,omitempty"`
-	ServerName string `json:"serverName,omitempty"`
+	Name            string            `json:"name" validate:"required"`
+	Alias           string            `json:"alias,omitempty"`
+	MaxPeriod       int64             `json:"maxPeriod,omitempty"`
+	MaxLines        int               `json:"maxLines,omitempty"`
+	ServerName      string            `json:"serverName,omitempty"`
+	ExpectedMaxGap  int64             `json:"expectedMaxGap,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Source          string            `json:"source,omitempty"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Pod             string            `json:"pod,omitempty"`
+	PodContainer    string            `json:"podContainer,omitempty"`
+	By              string            `json:"by,omitempty"`
+	LogDetails      bool              `json:"logDetails,omitempty"`
+	DetailKeys      []string          `json:"detailKeys,omitempty"`
+	LogFormat       string            `json:"logFormat,omitempty"`
+	CollectStdout   *bool             `json:"collectStdout,omitempty"`
+	CollectStderr   *bool             `json:"collectStderr,omitempty"`
+	InitialLines    int               `json:"initialLines,omitempty"`
+	SampleRate      int               `json:"sampleRate,omitempty"`
+	Transforms      []TransformRule   `json:"transforms,omitempty"`
+	InlineRetention *bool             `json:"inlineRetention,omitempty"`
+	SyslogForward   bool              `json:"syslogForward,omitempty"`
+	DockerHost      string            `json:"dockerHost,omitempty"`
+	JSONFields
+}
+
+type RelinkContainerRequest struct {
+	Name string `json:"name"`
 }
 
 type UpdateContainerRequest struct {
-	ContainerName string `json:"containerName"`
-	Alias         string `json:"alias"`
-	ServerName    string `json:"serverName"`
-	MaxPeriod     int64  `json:"maxPeriod"`
-	MaxLines      int    `json:"maxLines"`
+	ContainerName       string            `json:"containerName"`
+	Alias               string            `json:"alias"`
+	ServerName          string            `json:"serverName"`
+	MaxPeriod           int64             `json:"maxPeriod"`
+	MaxLines            int               `json:"maxLines"`
+	ExpectedMaxGap      *int64            `json:"expectedMaxGap,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	LogDetails          *bool             `json:"logDetails,omitempty"`
+	DetailKeys          []string          `json:"detailKeys,omitempty"`
+	LogFormat           string            `json:"logFormat,omitempty"`
+	CollectStdout       *bool             `json:"collectStdout,omitempty"`
+	CollectStderr       *bool             `json:"collectStderr,omitempty"`
+	InitialLines        *int              `json:"initialLines,omitempty"`
+	SampleRate          *int              `json:"sampleRate,omitempty"`
+	JSONTimestampField  *string           `json:"jsonTimestampField,omitempty"`
+	JSONTimestampFormat *string           `json:"jsonTimestampFormat,omitempty"`
+	JSONMessageField    *string           `json:"jsonMessageField,omitempty"`
+	JSONLevelField      *string           `json:"jsonLevelField,omitempty"`
+	Transforms          []TransformRule   `json:"transforms,omitempty"`
+	InlineRetention     *bool             `json:"inlineRetention,omitempty"`
+	SyslogForward       *bool             `json:"syslogForward,omitempty"`
+}
+
+// TransformRule rewrites every match of Pattern, a regular expression, in
+// collected lines with Replacement, which may refer to groups as $1 or ${name}.
+type TransformRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+type TransformPreviewRequest struct {
+	Transforms []TransformRule `json:"transforms"`
+	Limit      int             `json:"limit,omitempty"`
+}
+
+type TransformPreviewLine struct {
+	ID          string `json:"id"`
+	Message     string `json:"message"`
+	Transformed string `json:"transformed"`
+	Changed     bool   `json:"changed"`
+}
+
+type TransformPreviewResponse struct {
+	Lines []TransformPreviewLine `json:"lines"`
+}
+
+// JSONFields names the fields the json log format reads. Empty fields fall
+// back to the common names for each.
+type JSONFields struct {
+	JSONTimestampField  string `json:"jsonTimestampField,omitempty" db:"json_ts_field"`
+	JSONTimestampFormat string `json:"jsonTimestampFormat,omitempty" db:"json_ts_format"`
+	JSONMessageField    string `json:"jsonMessageField,omitempty" db:"json_message_field"`
+	JSONLevelField      string `json:"jsonLevelField,omitempty" db:"json_level_field"`
 }
 
 type AddContainerResponse struct {
 	Container Container `json:"container"`
 	Success   bool      `json:"success"`
 	Message   string    `json:"message,omitempty"`
+	DryRun    bool      `json:"dryRun,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
 }
 
 type ContainerListResponse struct {
@@ -53,6 +193,67 @@ type LogListResponse struct {
 	Total   int        `json:"total"`
 }
 
+type LevelCount struct {
+	Level string `json:"level"`
+	Count int    `json:"count"`
+}
+
+type LogContextResponse struct {
+	Logs  []LogEntry `json:"logs"`
+	Index int        `json:"index"`
+}
+
+type LogPollResponse struct {
+	Logs []LogEntry `json:"logs"`
+	Seq  int64      `json:"seq"`
+}
+
+// FieldAggregate counts logs by the value of one parsed JSON field. Total is
+// the number of logs that have the field and Distinct the number of values,
+// including those beyond the returned top values.
+type FieldAggregate struct {
+	Field    string            `json:"field"`
+	Values   []FieldValueCount `json:"values"`
+	Total    int               `json:"total"`
+	Distinct int               `json:"distinct"`
+}
+
+type FieldValueCount struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+type LevelListResponse struct {
+	Levels []LevelCount `json:"levels"`
+}
+
+type RenameServerRequest struct {
+	Name string `json:"name"`
+}
+
+type RenameServerResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+type CollectorInfo struct {
+	ContainerID   string `json:"containerId"`
+	ContainerName string `json:"containerName"`
+	State         string `json:"state"`
+	StartedAt     int64  `json:"startedAt"`
+}
+
+type CollectorListResponse struct {
+	Collectors []CollectorInfo `json:"collectors"`
+}
+
+// RetentionPreview is what applying retention limits would delete.
+// OldestTimestamp is the oldest log left afterwards, zero when none is.
+type RetentionPreview struct {
+	Removed         int64 `json:"removed"`
+	Remaining       int64 `json:"remaining"`
+	OldestTimestamp int64 `json:"oldestTimestamp,omitempty"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 	Code  string `json:"code,omitempty"`
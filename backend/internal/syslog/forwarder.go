@@ -0,0 +1,229 @@
+package syslog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+const (
+	queueSize    = 10000
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+
+	// A line that fails to write this many times in a row is dropped, so one
+	// the server keeps rejecting cannot hold up the rest of the queue.
+	maxWriteAttempts = 5
+
+	// maxUDPMessageSize keeps datagrams within what syslog receivers accept
+	// (RFC 5426 asks them to handle at least 2048 bytes); longer messages are
+	// truncated.
+	maxUDPMessageSize = 2048
+
+	// facilityUser is the "user-level messages" facility.
+	facilityUser = 1
+
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+)
+
+// Variables so tests need not wait out the backoff.
+var (
+	retryDelayBase = time.Second
+	retryDelayMax  = 30 * time.Second
+)
+
+var severities = map[string]int{
+	"ERROR": 3,
+	"WARN":  4,
+	"INFO":  6,
+	"DEBUG": 7,
+}
+
+// Forwarder sends log lines to a syslog server as RFC 5424 messages. Lines are
+// queued so a slow or unreachable server never holds up ingestion; when the
+// queue is full new lines are dropped and counted.
+type Forwarder struct {
+	network  string
+	addr     string
+	hostname string
+	queue    chan []byte
+	dropped  atomic.Int64
+	dial     func(network, addr string, timeout time.Duration) (net.Conn, error)
+}
+
+// NewForwarder parses an address such as tcp://host:514 or udp://host:514.
+// TCP messages use octet-counting framing (RFC 6587), UDP sends one message
+// per datagram.
+func NewForwarder(address string) (*Forwarder, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q", address)
+	}
+	if u.Scheme != "tcp" && u.Scheme != "udp" {
+		return nil, fmt.Errorf("unsupported syslog scheme %q, use tcp or udp", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("syslog address %q has no port", address)
+	}
+
+	hostname, _ := os.Hostname()
+	return &Forwarder{
+		network:  u.Scheme,
+		addr:     u.Host,
+		hostname: headerField(hostname, maxHostnameLen),
+		queue:    make(chan []byte, queueSize),
+		dial:     net.DialTimeout,
+	}, nil
+}
+
+// Send queues entry for forwarding under appName, normally the container's
+// alias.
+func (f *Forwarder) Send(entry models.LogEntry, appName string) {
+	select {
+	case f.queue <- f.frame(f.format(entry, appName)):
+	default:
+		if f.dropped.Add(1) == 1 {
+			log.Printf("[backend] Syslog queue is full, dropping lines until %s catches up", f.addr)
+		}
+	}
+}
+
+func (f *Forwarder) format(entry models.LogEntry, appName string) string {
+	level := entry.Level
+	if level == "" {
+		level = "INFO"
+	}
+	severity, ok := severities[level]
+	if !ok {
+		severity = severities["INFO"]
+	}
+
+	msgID := "-"
+	if entry.Stream != "" {
+		msgID = entry.Stream
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		facilityUser*8+severity,
+		time.Unix(0, entry.Timestamp).UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		f.hostname,
+		headerField(appName, maxAppNameLen),
+		msgID,
+		entry.Message)
+}
+
+func (f *Forwarder) frame(message string) []byte {
+	if f.network == "tcp" {
+		return []byte(strconv.Itoa(len(message)) + " " + message)
+	}
+	if len(message) > maxUDPMessageSize {
+		size := maxUDPMessageSize
+		for size > 0 && !utf8.RuneStart(message[size]) {
+			size--
+		}
+		message = message[:size]
+	}
+	return []byte(message)
+}
+
+// headerField makes s a valid RFC 5424 header field: printable ASCII without
+// spaces, at most maxLen characters, and "-" when empty.
+func headerField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Run writes queued lines until ctx is done, reconnecting with backoff when
+// the server is unreachable or a write fails. A line that failed to write is
+// retried on the next connection, up to maxWriteAttempts times before it is
+// dropped and counted.
+func (f *Forwarder) Run(ctx context.Context) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	var pending []byte
+	attempts := 0
+	delay := retryDelayBase
+	wait := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, retryDelayMax)
+		return true
+	}
+	for {
+		if pending == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case pending = <-f.queue:
+			}
+		}
+
+		if conn == nil {
+			c, err := f.dial(f.network, f.addr, dialTimeout)
+			if err != nil {
+				log.Printf("[backend] Failed to connect to syslog server %s, retrying in %s: %v", f.addr, delay, err)
+				if !wait() {
+					return
+				}
+				continue
+			}
+			conn = c
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := conn.Write(pending); err != nil {
+			conn.Close()
+			conn = nil
+			attempts++
+			if attempts >= maxWriteAttempts {
+				log.Printf("[backend] Failed to write to syslog server %s, dropping the line after %d attempts: %v", f.addr, attempts, err)
+				f.dropped.Add(1)
+				pending = nil
+				attempts = 0
+			} else {
+				log.Printf("[backend] Failed to write to syslog server %s, retrying in %s: %v", f.addr, delay, err)
+			}
+			if !wait() {
+				return
+			}
+			continue
+		}
+		pending = nil
+		attempts = 0
+		delay = retryDelayBase
+
+		if n := f.dropped.Swap(0); n > 0 {
+			log.Printf("[backend] Dropped %d lines that could not be queued or sent to the syslog server", n)
+		}
+	}
+}
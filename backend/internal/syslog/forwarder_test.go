@@ -0,0 +1,114 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+)
+
+// failingConn accepts the first ok writes and fails the rest.
+type failingConn struct {
+	net.Conn
+	mu      *sync.Mutex
+	writes  *[]string
+	ok      *int
+	failing *int
+}
+
+func (c failingConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if *c.ok == 0 {
+		*c.failing++
+		return 0, errors.New("connection reset by peer")
+	}
+	*c.ok--
+	*c.writes = append(*c.writes, string(b))
+	return len(b), nil
+}
+
+func (c failingConn) SetWriteDeadline(time.Time) error { return nil }
+func (c failingConn) Close() error                     { return nil }
+
+func TestRunDropsLineAfterRepeatedWriteFailures(t *testing.T) {
+	retryDelayBase, retryDelayMax = time.Millisecond, 4*time.Millisecond
+	defer func() { retryDelayBase, retryDelayMax = time.Second, 30*time.Second }()
+
+	f, err := NewForwarder("tcp://syslog.example:514")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu      sync.Mutex
+		writes  []string
+		ok      int
+		failing int
+	)
+	dials := 0
+	f.dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		mu.Lock()
+		dials++
+		mu.Unlock()
+		return failingConn{mu: &mu, writes: &writes, ok: &ok, failing: &failing}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Run(ctx)
+
+	f.Send(models.LogEntry{Message: "rejected", Timestamp: 1}, "app")
+	deadline := time.Now().Add(5 * time.Second)
+	for f.dropped.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the failing line was never dropped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	if failing != maxWriteAttempts || dials != maxWriteAttempts {
+		t.Fatalf("%d failed writes over %d connections, want %d", failing, dials, maxWriteAttempts)
+	}
+	ok = 1
+	mu.Unlock()
+
+	f.Send(models.LogEntry{Message: "accepted", Timestamp: 2}, "app")
+	for {
+		mu.Lock()
+		n := len(writes)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the next line was never written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.HasSuffix(writes[0], " accepted") {
+		t.Fatalf("wrote %q, want the accepted line", writes[0])
+	}
+	if n := f.dropped.Load(); n != 0 {
+		t.Fatalf("dropped count = %d after it was reported, want 0", n)
+	}
+}
+
+func TestUDPMessagesAreCapped(t *testing.T) {
+	f, err := NewForwarder("udp://syslog.example:514")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := f.frame(f.format(models.LogEntry{Message: strings.Repeat("é", maxUDPMessageSize)}, "app"))
+	if len(message) > maxUDPMessageSize {
+		t.Fatalf("datagram is %d bytes, want at most %d", len(message), maxUDPMessageSize)
+	}
+	if !strings.HasSuffix(string(message), "é") {
+		t.Fatal("truncation split a character")
+	}
+}
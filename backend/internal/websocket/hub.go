@@ -3,45 +3,101 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/docker-logs-viewer/backend/internal/models"
 	"github.com/gorilla/websocket"
 )
 
 type Client struct {
-	Conn        *websocket.Conn
-	Send        chan []byte
-	Hub         *Hub
-	ContainerID string
-	mu          sync.Mutex
+	Conn            *websocket.Conn
+	Send            chan []byte
+	Hub             *Hub
+	ContainerID     string
+	MergedIDs       map[string]bool
+	DeltaContainers bool
+	mu              sync.Mutex
+	sentContainers  map[string]models.Container
+	lastRead        atomic.Int64
+	// fullSince is when a send first found Send full, or zero once a send
+	// goes through again.
+	fullSince atomic.Int64
+
+	// OnPause and OnResume, when set, let the client send {"type": "pause"}
+	// to stop receiving live log messages and {"type": "resume"} to receive
+	// them again. They run on the read goroutine, OnPause after the client is
+	// paused with the seq of the last live log it was sent, or 0 if none,
+	// and OnResume after it is resumed.
+	OnPause  func(lastSeq int64)
+	OnResume func()
+
+	// liveMu makes pausing atomic with delivering a live log, so lastSeq is
+	// exactly the last one sent before the pause.
+	liveMu  sync.Mutex
+	paused  bool
+	lastSeq int64
 }
 
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+	clients        map[*Client]bool
+	broadcast      chan []byte
+	unregister     chan *Client
+	maxMessageSize int
+	mu             sync.RWMutex
+
+	queueSize int
+	queueMu   sync.Mutex
+	queues    map[string][]queuedMessage
+	ready     []string
+	wake      chan struct{}
+	dropped   uint64
 }
 
-func NewHub() *Hub {
+const (
+	// A writer whose Send channel stays full this long is treated as stuck.
+	// The write deadline should end a blocked write well before that, but a
+	// half-open connection can hang a write past it.
+	stuckClientTimeout  = 30 * time.Second
+	stuckClientInterval = 5 * time.Second
+)
+
+// queuedMessage is a marshaled container message; live marks log lines,
+// which paused clients skip and which are all that merged views receive.
+// seq is the stored line's seq, if any.
+type queuedMessage struct {
+	data []byte
+	live bool
+	seq  int64
+}
+
+func NewHub(maxMessageSize, queueSize int) *Hub {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		clients:        make(map[*Client]bool),
+		broadcast:      make(chan []byte, 256),
+		unregister:     make(chan *Client),
+		maxMessageSize: maxMessageSize,
+		queueSize:      queueSize,
+		queues:         make(map[string][]queuedMessage),
+		wake:           make(chan struct{}, 1),
 	}
 }
 
 func (h *Hub) Run() {
+	go h.dispatch()
+
+	watchdog := time.NewTicker(stuckClientInterval)
+	defer watchdog.Stop()
+
 	for {
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
@@ -50,17 +106,54 @@ func (h *Hub) Run() {
 			}
 			h.mu.Unlock()
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			h.mu.Lock()
 			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
+				if !client.trySend(message) {
 					close(client.Send)
 					delete(h.clients, client)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+		case <-watchdog.C:
+			h.dropStuckClients(time.Now())
+		}
+	}
+}
+
+// trySend queues message without blocking and keeps track of how long Send
+// has been full.
+func (c *Client) trySend(message []byte) bool {
+	select {
+	case c.Send <- message:
+		c.fullSince.Store(0)
+		return true
+	default:
+		c.fullSince.CompareAndSwap(0, time.Now().UnixNano())
+		return false
+	}
+}
+
+// dropStuckClients unregisters clients whose Send channel has been full for
+// stuckClientTimeout and closes their connections, which fails the write
+// their WritePump is blocked in.
+func (h *Hub) dropStuckClients(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		since := client.fullSince.Load()
+		if since == 0 || now.Sub(time.Unix(0, since)) < stuckClientTimeout {
+			continue
+		}
+		if len(client.Send) < cap(client.Send) {
+			// The writer caught up without another send noticing.
+			client.fullSince.Store(0)
+			continue
 		}
+		log.Printf("[websocket] Dropping client of %s: its writer has been stuck for %s", client.ContainerID, now.Sub(time.Unix(0, since)).Round(time.Second))
+		delete(h.clients, client)
+		close(client.Send)
+		client.Conn.Close()
 	}
 }
 
@@ -112,16 +205,68 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, _, err := c.Conn.ReadMessage()
+		_, data, err := c.Conn.ReadMessage()
 		if err != nil {
 
 			break
 		}
+		c.lastRead.Store(time.Now().UnixNano())
+
+		if c.OnResume == nil {
+			continue
+		}
+		var control struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &control) != nil {
+			continue
+		}
+		switch control.Type {
+		case "pause":
+			if lastSeq, ok := c.setPaused(true); ok && c.OnPause != nil {
+				c.OnPause(lastSeq)
+			}
+		case "resume":
+			if _, ok := c.setPaused(false); ok {
+				c.OnResume()
+			}
+		}
 	}
 }
 
+// setPaused pauses or resumes live messages and reports whether that changed
+// anything, along with the seq of the last live log sent.
+func (c *Client) setPaused(paused bool) (int64, bool) {
+	c.liveMu.Lock()
+	defer c.liveMu.Unlock()
+	changed := c.paused != paused
+	c.paused = paused
+	return c.lastSeq, changed
+}
+
+// sendLive sends a live log unless the client is paused.
+func (c *Client) sendLive(msg queuedMessage) {
+	c.liveMu.Lock()
+	defer c.liveMu.Unlock()
+	if c.paused {
+		return
+	}
+	if c.trySend(msg.data) && msg.seq > c.lastSeq {
+		c.lastSeq = msg.seq
+	}
+}
+
+// LastRead reports when the client last sent a message; pongs do not count.
+func (c *Client) LastRead() time.Time {
+	return time.Unix(0, c.lastRead.Load())
+}
+
+// Register adds the client before returning, so messages sent right after
+// it are delivered.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
 }
 
 func (h *Hub) Unregister(client *Client) {
@@ -129,7 +274,7 @@ func (h *Hub) Unregister(client *Client) {
 }
 
 func (h *Hub) Broadcast(message interface{}) {
-	msg, err := json.Marshal(message)
+	msg, err := h.marshal(message)
 	if err != nil {
 		log.Printf("[websocket] Failed to marshal message: %v", err)
 		return
@@ -142,7 +287,7 @@ func (h *Hub) Broadcast(message interface{}) {
 }
 
 func (h *Hub) SendToClient(client *Client, message interface{}) {
-	msg, err := json.Marshal(message)
+	msg, err := h.marshal(message)
 	if err != nil {
 		log.Printf("[websocket] Failed to marshal message: %v", err)
 		return
@@ -155,14 +300,204 @@ func (h *Hub) SendToClient(client *Client, message interface{}) {
 		return
 	}
 
+	client.trySend(msg)
+}
+
+// BroadcastToContainer queues message for the viewers of containerID. Each
+// container has its own bounded queue and dispatch takes one message from each
+// in turn, so a chatty container cannot delay the viewers of a quiet one.
+// Log lines for a container whose queue is full are dropped; other messages,
+// such as status changes and swaps, are rare and always queued, since a
+// client that misses one shows the wrong state until it reconnects.
+func (h *Hub) BroadcastToContainer(containerID string, message interface{}) {
+	msg, err := h.marshal(message)
+	if err != nil {
+		log.Printf("[websocket] Failed to marshal message: %v", err)
+		return
+	}
+	logMessage, live := message.(WSLogMessage)
+
+	h.queueMu.Lock()
+	queue := h.queues[containerID]
+	if live && len(queue) >= h.queueSize {
+		h.dropped++
+		h.queueMu.Unlock()
+		return
+	}
+	if len(queue) == 0 {
+		h.ready = append(h.ready, containerID)
+	}
+	h.queues[containerID] = append(queue, queuedMessage{data: msg, live: live, seq: logMessage.Payload.Seq})
+	h.queueMu.Unlock()
+
 	select {
-	case client.Send <- msg:
+	case h.wake <- struct{}{}:
 	default:
 	}
 }
 
-func (h *Hub) BroadcastToContainer(containerID string, message interface{}) {
+func (h *Hub) dispatch() {
+	for range h.wake {
+		for {
+			h.queueMu.Lock()
+			if len(h.ready) == 0 {
+				h.queueMu.Unlock()
+				break
+			}
+			containerID := h.ready[0]
+			h.ready = h.ready[1:]
+			queue := h.queues[containerID]
+			msg := queue[0]
+			if len(queue) == 1 {
+				delete(h.queues, containerID)
+			} else {
+				h.queues[containerID] = queue[1:]
+				h.ready = append(h.ready, containerID)
+			}
+			h.queueMu.Unlock()
+
+			h.deliver(containerID, msg)
+		}
+	}
+}
+
+func (h *Hub) deliver(containerID string, msg queuedMessage) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if client.ContainerID != containerID && !(msg.live && client.MergedIDs[containerID]) {
+			continue
+		}
+		if msg.live {
+			client.sendLive(msg)
+		} else {
+			client.trySend(msg.data)
+		}
+	}
+}
+
+func (h *Hub) QueueStats() (queued int, dropped uint64) {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+
+	for _, queue := range h.queues {
+		queued += len(queue)
+	}
+	return queued, h.dropped
+}
+
+func (h *Hub) marshal(message interface{}) ([]byte, error) {
 	msg, err := json.Marshal(message)
+	if err != nil || h.maxMessageSize <= 0 || len(msg) <= h.maxMessageSize {
+		return msg, err
+	}
+
+	switch m := message.(type) {
+	case WSLogMessage:
+		m.Payload = fitEntry(m.Payload, entrySize(m.Payload)-(len(msg)-h.maxMessageSize))
+		if msg, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	case WSLogsBatchMessage:
+		if len(m.Payload) == 0 {
+			break
+		}
+		// Every entry gets an equal share of what the envelope and the
+		// commas between entries leave.
+		envelope := len(msg)
+		for _, entry := range m.Payload {
+			envelope -= entrySize(entry)
+		}
+		budget := (h.maxMessageSize - envelope) / len(m.Payload)
+		logs := make([]models.LogEntry, len(m.Payload))
+		for i, entry := range m.Payload {
+			logs[i] = fitEntry(entry, budget)
+		}
+		m.Payload = logs
+		if msg, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(msg) > h.maxMessageSize {
+		log.Printf("[websocket] Sending a %d byte message over the %d byte limit: it does not fit even with its log messages emptied", len(msg), h.maxMessageSize)
+	}
+	return msg, nil
+}
+
+func entrySize(entry models.LogEntry) int {
+	data, _ := json.Marshal(entry)
+	return len(data)
+}
+
+// fitEntry shrinks entry until it marshals to at most budget bytes, dropping
+// its details and fields before cutting its message.
+func fitEntry(entry models.LogEntry, budget int) models.LogEntry {
+	size := entrySize(entry)
+	if size <= budget {
+		return entry
+	}
+	if entry.Details != nil || entry.Fields != nil {
+		entry.Details, entry.Fields = nil, nil
+		entry.Truncated = true
+		if size = entrySize(entry); size <= budget {
+			return entry
+		}
+	}
+	// Escaping makes some characters take more room in JSON than in the
+	// message, so measure again after each cut.
+	for size > budget && entry.Message != "" {
+		entry = truncateEntry(entry, len(entry.Message)-(size-budget))
+		size = entrySize(entry)
+	}
+	return entry
+}
+
+func truncateEntry(entry models.LogEntry, size int) models.LogEntry {
+	if size >= len(entry.Message) {
+		return entry
+	}
+	if size < 0 {
+		size = 0
+	}
+	for size > 0 && !utf8.RuneStart(entry.Message[size]) {
+		size--
+	}
+	entry.Message = entry.Message[:size]
+	entry.Truncated = true
+	return entry
+}
+
+// SendContainers sends the full list to a containers WebSocket client and
+// makes it the baseline later broadcasts are compared against.
+func (h *Hub) SendContainers(client *Client, containers []models.Container) {
+	msg, err := h.marshal(NewContainersMessage(containers))
+	if err != nil {
+		log.Printf("[websocket] Failed to marshal message: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.trySend(msg) {
+		client.sentContainers = containerSet(containers)
+	}
+}
+
+// BroadcastContainers sends the list to every client, as a delta to those
+// that asked for one. Only clients that got SendContainers, the containers
+// WebSocket ones, keep a baseline, and it moves only once a send is queued,
+// so a delta dropped on a full queue is sent again with the next one.
+func (h *Hub) BroadcastContainers(containers []models.Container) {
+	full, err := json.Marshal(NewContainersMessage(containers))
 	if err != nil {
 		log.Printf("[websocket] Failed to marshal message: %v", err)
 		return
@@ -172,13 +507,102 @@ func (h *Hub) BroadcastToContainer(containerID string, message interface{}) {
 	defer h.mu.RUnlock()
 
 	for client := range h.clients {
-		if client.ContainerID == containerID {
-			select {
-			case client.Send <- msg:
-			default:
-			}
+		client.sendContainers(containers, full)
+	}
+}
+
+func (c *Client) sendContainers(containers []models.Container, full []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sentContainers == nil {
+		c.trySend(full)
+		return
+	}
+
+	msg := full
+	if c.DeltaContainers {
+		delta := c.containersDelta(containers)
+		if len(delta.Changed) == 0 && len(delta.Removed) == 0 {
+			return
+		}
+		var err error
+		msg, err = json.Marshal(delta)
+		if err != nil {
+			log.Printf("[websocket] Failed to marshal message: %v", err)
+			return
+		}
+	}
+
+	if c.trySend(msg) {
+		c.sentContainers = containerSet(containers)
+	}
+}
+
+// containersDelta compares containers with the baseline. The caller holds
+// c.mu.
+func (c *Client) containersDelta(containers []models.Container) WSContainerDeltaMessage {
+	delta := NewContainerDeltaMessage()
+	current := containerSet(containers)
+	for _, container := range containers {
+		if previous, ok := c.sentContainers[container.ID]; !ok || !reflect.DeepEqual(previous, container) {
+			delta.Changed = append(delta.Changed, container)
+		}
+	}
+	for id := range c.sentContainers {
+		if _, ok := current[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	return delta
+}
+
+func containerSet(containers []models.Container) map[string]models.Container {
+	set := make(map[string]models.Container, len(containers))
+	for _, container := range containers {
+		set[container.ID] = container
+	}
+	return set
+}
+
+func (h *Hub) IsRegistered(client *Client) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.clients[client]
+	return ok
+}
+
+type ContainerClients struct {
+	ContainerID string   `json:"containerId"`
+	Count       int      `json:"count"`
+	RemoteAddrs []string `json:"remoteAddrs"`
+}
+
+func (h *Hub) Snapshot() []ContainerClients {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	byContainer := make(map[string]*ContainerClients)
+	var order []string
+	for client := range h.clients {
+		entry, ok := byContainer[client.ContainerID]
+		if !ok {
+			entry = &ContainerClients{ContainerID: client.ContainerID, RemoteAddrs: []string{}}
+			byContainer[client.ContainerID] = entry
+			order = append(order, client.ContainerID)
 		}
+		entry.Count++
+		if client.Conn != nil {
+			entry.RemoteAddrs = append(entry.RemoteAddrs, client.Conn.RemoteAddr().String())
+		}
+	}
+
+	sort.Strings(order)
+	snapshot := make([]ContainerClients, 0, len(order))
+	for _, id := range order {
+		snapshot = append(snapshot, *byContainer[id])
 	}
+	return snapshot
 }
 
 func (h *Hub) Count() int {
@@ -192,9 +616,16 @@ type WSLogMessage struct {
 	Payload models.LogEntry `json:"payload"`
 }
 
+// WSLogsBatchMessage carries stored logs, newest first. Replace marks a batch
+// that supersedes whatever the client shows; later batches without it extend
+// the same history. Generation is set on batches sent after a swap. Gap marks
+// logs stored while the client was paused, newer than what it shows.
 type WSLogsBatchMessage struct {
-	Type    string            `json:"type"`
-	Payload []models.LogEntry `json:"payload"`
+	Type       string            `json:"type"`
+	Payload    []models.LogEntry `json:"payload"`
+	Replace    bool              `json:"replace"`
+	Generation int64             `json:"generation,omitempty"`
+	Gap        bool              `json:"gap,omitempty"`
 }
 
 type WSContainerSwappedMessage struct {
@@ -208,11 +639,36 @@ type WSContainersMessage struct {
 	Containers []models.Container `json:"containers"`
 }
 
+type WSContainerDeltaMessage struct {
+	Type    string             `json:"type"`
+	Changed []models.Container `json:"changed"`
+	Removed []string           `json:"removed"`
+}
+
+type WSContainerAddedMessage struct {
+	Type      string           `json:"type"`
+	Container models.Container `json:"container"`
+}
+
+type WSContainerRemovedMessage struct {
+	Type        string `json:"type"`
+	ContainerID string `json:"containerId"`
+}
+
 type WSControlMessage struct {
 	Type    string `json:"type"`
 	Payload string `json:"payload"`
 }
 
+// WSHelloMessage is the first message on every connection. Epoch changes
+// whenever the server restarts, so a client that sees a different epoch than
+// before reconnecting knows its cached logs may be stale.
+type WSHelloMessage struct {
+	Type             string `json:"type"`
+	Epoch            int64  `json:"epoch"`
+	LastLogTimestamp int64  `json:"lastLogTimestamp,omitempty"`
+}
+
 type WSStatusMessage struct {
 	Type   string `json:"type"`
 	Status string `json:"status"`
@@ -232,6 +688,23 @@ func NewLogsBatchMessage(logs []models.LogEntry) WSLogsBatchMessage {
 	}
 }
 
+func NewSwapBatchMessage(logs []models.LogEntry, generation int64) WSLogsBatchMessage {
+	return WSLogsBatchMessage{
+		Type:       "logs_batch",
+		Payload:    logs,
+		Replace:    true,
+		Generation: generation,
+	}
+}
+
+func NewGapBatchMessage(logs []models.LogEntry) WSLogsBatchMessage {
+	return WSLogsBatchMessage{
+		Type:    "logs_batch",
+		Payload: logs,
+		Gap:     true,
+	}
+}
+
 func NewContainerSwappedMessage(containerID, containerName string) WSContainerSwappedMessage {
 	return WSContainerSwappedMessage{
 		Type:             "container_swapped",
@@ -247,6 +720,28 @@ func NewContainersMessage(containers []models.Container) WSContainersMessage {
 	}
 }
 
+func NewContainerDeltaMessage() WSContainerDeltaMessage {
+	return WSContainerDeltaMessage{
+		Type:    "containers_delta",
+		Changed: []models.Container{},
+		Removed: []string{},
+	}
+}
+
+func NewContainerAddedMessage(container models.Container) WSContainerAddedMessage {
+	return WSContainerAddedMessage{
+		Type:      "container_added",
+		Container: container,
+	}
+}
+
+func NewContainerRemovedMessage(containerID string) WSContainerRemovedMessage {
+	return WSContainerRemovedMessage{
+		Type:        "container_removed",
+		ContainerID: containerID,
+	}
+}
+
 func NewControlMessage(action string) WSControlMessage {
 	return WSControlMessage{
 		Type:    "control",
@@ -261,9 +756,24 @@ func NewErrorMessage(err string) WSControlMessage {
 	}
 }
 
+func NewContainerUnavailableMessage(reason string) WSControlMessage {
+	return WSControlMessage{
+		Type:    "container_unavailable",
+		Payload: reason,
+	}
+}
+
 func NewStatusMessage(status string) WSStatusMessage {
 	return WSStatusMessage{
 		Type:   "status",
 		Status: status,
 	}
 }
+
+func NewHelloMessage(epoch, lastLogTimestamp int64) WSHelloMessage {
+	return WSHelloMessage{
+		Type:             "hello",
+		Epoch:            epoch,
+		LastLogTimestamp: lastLogTimestamp,
+	}
+}
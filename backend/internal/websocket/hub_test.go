@@ -0,0 +1,273 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-logs-viewer/backend/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+func newTestClient(h *Hub, containerID string) *Client {
+	client := &Client{Hub: h, ContainerID: containerID, Send: make(chan []byte, 256)}
+	h.Register(client)
+	return client
+}
+
+// received returns the types of the messages sent to client within wait.
+func received(t *testing.T, client *Client, wait time.Duration) []string {
+	t.Helper()
+	var types []string
+	timeout := time.After(wait)
+	for {
+		select {
+		case data := <-client.Send:
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, msg.Type)
+		case <-timeout:
+			return types
+		}
+	}
+}
+
+func TestMergedClientsOnlyReceiveLogs(t *testing.T) {
+	h := NewHub(0, 16)
+	go h.Run()
+
+	single := newTestClient(h, "a")
+	merged := newTestClient(h, "merged")
+	merged.MergedIDs = map[string]bool{"a": true}
+
+	h.BroadcastToContainer("a", NewStatusMessage("log_gap"))
+	h.BroadcastToContainer("a", NewLogMessage(models.LogEntry{Message: "line"}))
+	h.BroadcastToContainer("a", NewContainerSwappedMessage("new", "new"))
+
+	if got := received(t, single, 100*time.Millisecond); len(got) != 3 {
+		t.Fatalf("single view got %v, want all three messages", got)
+	}
+	if got := received(t, merged, 100*time.Millisecond); len(got) != 1 || got[0] != "log" {
+		t.Fatalf("merged view got %v, want only the log", got)
+	}
+}
+
+func TestFullQueueDropsOnlyLogLines(t *testing.T) {
+	h := NewHub(0, 4)
+	noisy := newTestClient(h, "noisy")
+	merged := newTestClient(h, "merged")
+	merged.MergedIDs = map[string]bool{"noisy": true, "quiet": true}
+
+	// Nothing is dispatched yet, so the noisy container's queue fills up.
+	for i := 0; i < 10; i++ {
+		h.BroadcastToContainer("noisy", NewLogMessage(models.LogEntry{Message: "noisy"}))
+	}
+	h.BroadcastToContainer("noisy", NewStatusMessage("crash_looping"))
+	h.BroadcastToContainer("quiet", NewLogMessage(models.LogEntry{Message: "quiet"}))
+
+	if queued, dropped := h.QueueStats(); queued != 6 || dropped != 6 {
+		t.Fatalf("queued %d, dropped %d, want 6 and 6", queued, dropped)
+	}
+
+	go h.Run()
+
+	got := received(t, noisy, 100*time.Millisecond)
+	if len(got) != 5 || got[4] != "status" {
+		t.Fatalf("noisy view got %v, want 4 logs and the status", got)
+	}
+
+	// The quiet container's line goes out right after the first noisy one
+	// rather than behind the whole backlog.
+	var messages []string
+	for len(messages) < 5 {
+		select {
+		case data := <-merged.Send:
+			var msg WSLogMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, msg.Payload.Message)
+		case <-time.After(time.Second):
+			t.Fatalf("merged view got %v, want 5 lines", messages)
+		}
+	}
+	if messages[1] != "quiet" {
+		t.Fatalf("merged view got %v, want the quiet line second", messages)
+	}
+}
+
+func TestPauseReportsLastDeliveredSeq(t *testing.T) {
+	h := NewHub(0, 16)
+	go h.Run()
+	client := newTestClient(h, "a")
+
+	send := func(seq int64) {
+		h.BroadcastToContainer("a", NewLogMessage(models.LogEntry{Seq: seq}))
+	}
+	send(1)
+	send(2)
+	if got := received(t, client, 100*time.Millisecond); len(got) != 2 {
+		t.Fatalf("got %v before pausing, want 2 logs", got)
+	}
+
+	if lastSeq, ok := client.setPaused(true); !ok || lastSeq != 2 {
+		t.Fatalf("pause = %d, %v, want 2, true", lastSeq, ok)
+	}
+	send(3)
+	if got := received(t, client, 100*time.Millisecond); len(got) != 0 {
+		t.Fatalf("paused client got %v", got)
+	}
+
+	client.setPaused(false)
+	send(4)
+	received(t, client, 100*time.Millisecond)
+	if lastSeq, _ := client.setPaused(true); lastSeq != 4 {
+		t.Fatalf("last seq = %d, want 4", lastSeq)
+	}
+}
+
+func TestMarshalKeepsMessagesUnderLimit(t *testing.T) {
+	const limit = 2048
+	// An empty batch cannot shrink; it must not divide by zero trying.
+	if _, err := NewHub(8, 1).marshal(NewLogsBatchMessage([]models.LogEntry{})); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHub(limit, 1)
+	details := map[string]string{"trace": strings.Repeat("d", 4*limit)}
+
+	for name, message := range map[string]interface{}{
+		"details, no message":  NewLogMessage(models.LogEntry{Details: details}),
+		"long escaped message": NewLogMessage(models.LogEntry{Message: strings.Repeat(`"`, 4*limit)}),
+		"batch with details": NewLogsBatchMessage([]models.LogEntry{
+			{Message: "short", Details: details},
+			{Message: strings.Repeat("é", limit), Fields: map[string]interface{}{"big": strings.Repeat("f", 4*limit)}},
+			{Message: strings.Repeat("\n", limit)},
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			msg, err := h.marshal(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msg) > limit {
+				t.Fatalf("marshaled to %d bytes, want at most %d", len(msg), limit)
+			}
+			if !json.Valid(msg) {
+				t.Fatal("marshaled to invalid JSON")
+			}
+		})
+	}
+}
+
+func TestFullContainersBroadcastUpdatesBaseline(t *testing.T) {
+	h := NewHub(0, 16)
+	client := newTestClient(h, "containers")
+	h.SendContainers(client, []models.Container{{ID: "a", ContainerName: "web"}})
+	received(t, client, 50*time.Millisecond)
+
+	containers := []models.Container{{ID: "a", ContainerName: "web"}, {ID: "b", ContainerName: "db"}}
+	h.BroadcastContainers(containers)
+	if got := received(t, client, 50*time.Millisecond); len(got) != 1 || got[0] != "containers" {
+		t.Fatalf("received %v, want one full containers message", got)
+	}
+
+	delta := client.containersDelta(containers)
+	if len(delta.Changed) != 0 || len(delta.Removed) != 0 {
+		t.Fatalf("delta after a full send = %+v, want nothing changed", delta)
+	}
+}
+
+func TestDroppedContainersDeltaIsResent(t *testing.T) {
+	h := NewHub(0, 16)
+	client := &Client{Hub: h, ContainerID: "containers", DeltaContainers: true, Send: make(chan []byte, 1)}
+	h.Register(client)
+
+	// The snapshot fills the queue, so the first delta is dropped.
+	h.SendContainers(client, []models.Container{{ID: "a", Status: "running"}})
+	changed := []models.Container{{ID: "a", Status: "exited"}}
+	h.BroadcastContainers(changed)
+	if got := received(t, client, 50*time.Millisecond); len(got) != 1 || got[0] != "containers" {
+		t.Fatalf("received %v, want only the snapshot", got)
+	}
+
+	h.BroadcastContainers(changed)
+	select {
+	case data := <-client.Send:
+		var delta WSContainerDeltaMessage
+		if err := json.Unmarshal(data, &delta); err != nil {
+			t.Fatal(err)
+		}
+		if len(delta.Changed) != 1 || delta.Changed[0].Status != "exited" {
+			t.Fatalf("delta = %+v, want the dropped change again", delta)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the dropped delta was not sent again")
+	}
+}
+
+func TestLogViewersKeepNoContainersBaseline(t *testing.T) {
+	h := NewHub(0, 16)
+	viewer := newTestClient(h, "web")
+
+	h.BroadcastContainers([]models.Container{{ID: "a"}})
+	if got := received(t, viewer, 50*time.Millisecond); len(got) != 1 || got[0] != "containers" {
+		t.Fatalf("received %v, want one full containers message", got)
+	}
+	if viewer.sentContainers != nil {
+		t.Fatalf("log viewer keeps a baseline of %d containers", len(viewer.sentContainers))
+	}
+}
+
+// testConn returns the server side of a WebSocket connection.
+func testConn(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return <-conns
+}
+
+func TestStuckClientIsDropped(t *testing.T) {
+	h := NewHub(0, 16)
+	client := &Client{Hub: h, ContainerID: "web", Conn: testConn(t), Send: make(chan []byte, 1)}
+	h.Register(client)
+
+	// Nothing drains Send, as with a WritePump blocked on a dead peer.
+	client.trySend([]byte("first"))
+	client.trySend([]byte("second"))
+
+	h.dropStuckClients(time.Now())
+	if !h.IsRegistered(client) {
+		t.Fatal("client dropped before it was stuck for the timeout")
+	}
+
+	h.dropStuckClients(time.Now().Add(stuckClientTimeout + time.Second))
+	if h.IsRegistered(client) {
+		t.Fatal("stuck client is still registered")
+	}
+	<-client.Send
+	if _, ok := <-client.Send; ok {
+		t.Fatal("Send of a dropped client is still open")
+	}
+}
@@ -49,6 +49,7 @@ type LogAction =
   | { type: "SET_LOGS"; logs: LogEntry[] }
   | { type: "FORCE_ADD_LOG"; log: LogEntry }
   | { type: "FORCE_SET_LOGS"; logs: LogEntry[] }
+  | { type: "APPEND_LOGS"; logs: LogEntry[] }
   | { type: "TOGGLE_FILTER"; level: LogLevel }
   | { type: "CLEAR_FILTERS" }
   | { type: "SET_FILTERS"; filters: Set<LogLevel> }
@@ -122,6 +123,12 @@ function logReducer(state: LogState, action: LogAction): LogState {
       const filteredLogs = filterLogs(action.logs, state.activeFilters, state.searchQuery, state.sortOrder)
       return { ...state, logs: action.logs, filteredLogs }
     }
+    case "APPEND_LOGS": {
+      const seenIds = new Set(state.logs.map(l => l.id))
+      const newLogs = [...state.logs, ...action.logs.filter(l => !seenIds.has(l.id))].slice(0, 10000)
+      const filteredLogs = filterLogs(newLogs, state.activeFilters, state.searchQuery, state.sortOrder)
+      return { ...state, logs: newLogs, filteredLogs }
+    }
     case "ADD_LOG": {
       if (state.isPaused) return state
 
@@ -317,6 +324,12 @@ export default function NetworkAnalyzerPage() {
         const msg = JSON.parse(event.data)
         if (msg.type === "containers") {
           setContainers(msg.containers || [])
+        } else if (msg.type === "container_added") {
+          setContainers((prev) =>
+            prev.some((c) => c.id === msg.container.id) ? prev : [msg.container, ...prev]
+          )
+        } else if (msg.type === "container_removed") {
+          setContainers((prev) => prev.filter((c) => c.id !== msg.containerId))
         }
       } catch {
         console.error("[frontend] Failed to parse containers message")
@@ -490,7 +503,7 @@ export default function NetworkAnalyzerPage() {
             message: msg.payload.message,
           }})
         } else if (msg.type === "logs_batch") {
-          dispatch({ type: "FORCE_SET_LOGS", logs: msg.payload.map((l: any) => ({
+          dispatch({ type: msg.replace === false ? "APPEND_LOGS" : "FORCE_SET_LOGS", logs: msg.payload.map((l: any) => ({
             id: l.id || `${l.containerId}-${l.timestamp}`,
             containerId: l.containerId,
             timestamp: typeof l.timestamp === "number" ? l.timestamp : new Date(l.timestamp).getTime(),
@@ -10,6 +10,33 @@ export interface TrackedContainer {
   maxPeriod: number
   maxLines: number
   serverName: string
+  expectedMaxGap: number
+  metadata: Record<string, string>
+  source: 'docker' | 'kubernetes'
+  namespace?: string
+  pod?: string
+  podContainer?: string
+  retentionUnlimited?: boolean
+  logBytes: number
+  logDetails: boolean
+  detailKeys?: string[]
+  logFormat: 'docker' | 'journald' | 'json'
+  collectStdout: boolean
+  collectStderr: boolean
+  initialLines?: number
+  sampleRate: number
+  crashLooping?: boolean
+  logConfig?: { type: string; config?: Record<string, string> }
+  startedAt?: number
+  archived?: boolean
+  transforms?: TransformRule[]
+  inlineRetention: boolean
+  syslogForward?: boolean
+  dockerHost?: string
+  jsonTimestampField?: string
+  jsonTimestampFormat?: string
+  jsonMessageField?: string
+  jsonLevelField?: string
 }
 
 export interface LogEntry {
@@ -17,4 +44,26 @@ export interface LogEntry {
   containerId: string
   timestamp: number
   message: string
+  truncated?: boolean
+  localTime?: string
+  level?: string
+  stream?: 'stdout' | 'stderr'
+  details?: Record<string, string>
+  fields?: Record<string, unknown>
+  pinned?: boolean
+  alias?: string
+  annotations?: Annotation[]
+}
+
+export interface TransformRule {
+  pattern: string
+  replacement: string
+}
+
+export interface Annotation {
+  id: string
+  logId: string
+  note: string
+  author?: string
+  createdAt: number
 }